@@ -0,0 +1,225 @@
+// Package baggagepolicy decides which baggage a service accepts from its
+// callers and which baggage members become attributes of its spans.
+package baggagepolicy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+
+	"tracing/redact"
+)
+
+// Policy filters the baggage extracted from incoming requests and selects
+// the members promoted to span attributes. A nil *Policy accepts all
+// baggage and promotes nothing.
+type Policy struct {
+	allowed    []string
+	maxMembers int
+	maxBytes   int
+	trusted    []*net.IPNet
+	promote    []attribute.Key
+	redactor   *redact.Redactor
+	redactorOK bool
+}
+
+// Option configures a Policy.
+type Option func(*Policy)
+
+// New returns a Policy configured by opts.
+func New(opts ...Option) *Policy {
+	p := &Policy{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithAllowedKeys accepts only the baggage members with one of keys; a key
+// ending in "*" matches every key with that prefix. By default every key
+// is accepted.
+func WithAllowedKeys(keys ...string) Option {
+	return func(p *Policy) {
+		p.allowed = append(p.allowed, keys...)
+	}
+}
+
+// WithMaxMembers accepts at most n baggage members, dropping the others in
+// key order. n <= 0 means no limit.
+func WithMaxMembers(n int) Option {
+	return func(p *Policy) {
+		p.maxMembers = n
+	}
+}
+
+// WithMaxBytes accepts baggage members, in key order, as long as their
+// encoding in the baggage header fits in n bytes. n <= 0 means no limit.
+func WithMaxBytes(n int) Option {
+	return func(p *Policy) {
+		p.maxBytes = n
+	}
+}
+
+// WithTrustedNetworks drops all baggage sent by callers whose address is
+// outside networks, e.g. clients on the internet talking to an edge
+// service. By default every caller is trusted.
+func WithTrustedNetworks(networks ...*net.IPNet) Option {
+	return func(p *Policy) {
+		p.trusted = append(p.trusted, networks...)
+	}
+}
+
+// WithPromotedKeys copies the baggage members with one of keys onto every
+// span started by instrumentation using the policy, as attributes of the
+// same key.
+func WithPromotedKeys(keys ...string) Option {
+	return func(p *Policy) {
+		for _, k := range keys {
+			p.promote = append(p.promote, attribute.Key(k))
+		}
+	}
+}
+
+// WithRedactor specifies the redactor applied to promoted members. If none
+// is specified, the global one registered with redact.SetGlobal is used.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(p *Policy) {
+		p.redactor = r
+		p.redactorOK = true
+	}
+}
+
+// ParseNetworks parses CIDR notations, e.g. "10.0.0.0/8", for
+// WithTrustedNetworks.
+func ParseNetworks(cidrs ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("baggagepolicy: %w", err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Filter returns the members of baggage sent by the caller at peer, a
+// host:port or host address, that the policy accepts.
+func (p *Policy) Filter(members []attribute.KeyValue, peer string) []attribute.KeyValue {
+	if p == nil || len(members) == 0 {
+		return members
+	}
+	if !p.Trusted(peer) {
+		return nil
+	}
+
+	accepted := make([]attribute.KeyValue, 0, len(members))
+	size := 0
+	for _, kv := range members {
+		if !p.allowedKey(string(kv.Key)) {
+			continue
+		}
+		if p.maxMembers > 0 && len(accepted) == p.maxMembers {
+			break
+		}
+		if p.maxBytes > 0 {
+			n := memberLen(kv)
+			if len(accepted) > 0 {
+				n++ // the comma separating members
+			}
+			if size+n > p.maxBytes {
+				continue
+			}
+			size += n
+		}
+		accepted = append(accepted, kv)
+	}
+	return accepted
+}
+
+// Apply replaces the baggage of ctx, extracted from a request of the
+// caller at peer, with the members the policy accepts.
+func (p *Policy) Apply(ctx context.Context, peer string) context.Context {
+	if p == nil {
+		return ctx
+	}
+	set := baggage.Set(ctx)
+	members := p.Filter(set.ToSlice(), peer)
+	if len(members) == set.Len() {
+		return ctx
+	}
+	return baggage.ContextWithValues(baggage.ContextWithEmpty(ctx), members...)
+}
+
+// Trusted reports whether baggage sent by the caller at peer is accepted.
+// Callers whose address cannot be parsed are not trusted when trusted
+// networks are configured.
+func (p *Policy) Trusted(peer string) bool {
+	if p == nil || len(p.trusted) == 0 {
+		return true
+	}
+	host := peer
+	if h, _, err := net.SplitHostPort(peer); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attributes returns the promoted members of the baggage of ctx as span
+// attributes, redacted.
+func (p *Policy) Attributes(ctx context.Context) []attribute.KeyValue {
+	if p == nil || len(p.promote) == 0 {
+		return nil
+	}
+
+	var attrs []attribute.KeyValue
+	for _, key := range p.promote {
+		if v := baggage.Value(ctx, key); v.Type() != attribute.INVALID {
+			attrs = append(attrs, attribute.KeyValue{Key: key, Value: v})
+		}
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	r := p.redactor
+	if !p.redactorOK {
+		r = redact.Global()
+	}
+	return r.Attributes(attrs)
+}
+
+func (p *Policy) allowedKey(key string) bool {
+	if len(p.allowed) == 0 {
+		return true
+	}
+	for _, allowed := range p.allowed {
+		if prefix := strings.TrimSuffix(allowed, "*"); prefix != allowed {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// memberLen returns the length of kv encoded as a baggage header member.
+func memberLen(kv attribute.KeyValue) int {
+	return len(kv.Key) + 1 + len(url.QueryEscape(kv.Value.Emit()))
+}
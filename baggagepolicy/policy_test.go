@@ -0,0 +1,97 @@
+package baggagepolicy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+
+	"tracing/redact"
+)
+
+func TestFilter(t *testing.T) {
+	members := []attribute.KeyValue{
+		attribute.String("app.tenant", "acme"),
+		attribute.String("app.user", "adam"),
+		attribute.String("debug", "on"),
+		attribute.String("username", "donuts"),
+	}
+	internal, err := ParseNetworks("10.0.0.0/8", "::1/128")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		peer   string
+		want   []string
+	}{
+		{"nil policy", nil, "203.0.113.1:1234", []string{"app.tenant", "app.user", "debug", "username"}},
+		{"allowed keys", New(WithAllowedKeys("app.*", "username")), "", []string{"app.tenant", "app.user", "username"}},
+		{"max members", New(WithMaxMembers(2)), "", []string{"app.tenant", "app.user"}},
+		// "app.tenant=acme,debug=on" is 24 bytes; app.user would make it 38.
+		{"max bytes", New(WithMaxBytes(24)), "", []string{"app.tenant", "debug"}},
+		{"trusted peer", New(WithTrustedNetworks(internal...)), "10.1.2.3:5000", []string{"app.tenant", "app.user", "debug", "username"}},
+		{"trusted IPv6 peer", New(WithTrustedNetworks(internal...)), "[::1]:5000", []string{"app.tenant", "app.user", "debug", "username"}},
+		{"untrusted peer", New(WithTrustedNetworks(internal...)), "203.0.113.1:1234", nil},
+		{"unknown peer", New(WithTrustedNetworks(internal...)), "bufconn", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, kv := range tt.policy.Filter(members, tt.peer) {
+				got = append(got, string(kv.Key))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := baggage.ContextWithValues(context.Background(),
+		attribute.String("username", "donuts"),
+		attribute.String("session", "secret"),
+	)
+	ctx = New(WithAllowedKeys("username")).Apply(ctx, "")
+
+	if got := baggage.Value(ctx, "username").AsString(); got != "donuts" {
+		t.Errorf("username = %q, want donuts", got)
+	}
+	if got := baggage.Value(ctx, "session"); got.Type() != attribute.INVALID {
+		t.Errorf("session = %v, want it dropped", got.Emit())
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	ctx := baggage.ContextWithValues(context.Background(),
+		attribute.String("username", "donuts"),
+		attribute.String("password", "hunter2"),
+		attribute.String("tenant", "acme"),
+	)
+	p := New(
+		WithPromotedKeys("username", "password", "missing"),
+		WithRedactor(redact.New(redact.WithFields(redact.Mask, "password"))),
+	)
+
+	want := []attribute.KeyValue{
+		attribute.String("username", "donuts"),
+		attribute.String("password", "[REDACTED]"),
+	}
+	if got := p.Attributes(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes = %v, want %v", got, want)
+	}
+	if got := (*Policy)(nil).Attributes(ctx); got != nil {
+		t.Errorf("nil policy Attributes = %v, want none", got)
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	if _, err := ParseNetworks("10.0.0.0/8", "not a network"); err == nil {
+		t.Error("ParseNetworks accepted an invalid network")
+	}
+}
@@ -2,197 +2,119 @@ package main
 
 import (
 	"context"
+	"net/http"
+	"os"
+	"time"
+	"tracing/grpctrace"
+	"tracing/iristrace"
 	tracing "tracing/proto"
+	"tracing/tracelog"
 	"tracing/tracer"
 
 	"github.com/kataras/iris/v12"
-	"go.opentelemetry.io/contrib"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
 )
 
-const (
-	defaultTracerName = "go.opentelemetry.io/contrib/instrumentation/github.com/Shopify/sarama/otelsarama"
-
-	kafkaPartitionKey = attribute.Key("messaging.kafka.partition")
-)
-
-type metadataSupplier struct {
-	metadata *metadata.MD
-}
-
-func (s *metadataSupplier) Get(key string) string {
-	values := s.metadata.Get(key)
-	if len(values) == 0 {
-		return ""
-	}
-	return values[0]
-}
-
-func (s *metadataSupplier) Set(key string, value string) {
-	s.metadata.Set(key, value)
-}
-
-func (s *metadataSupplier) Keys() []string {
-	out := make([]string, 0, len(*s.metadata))
-	for key := range *s.metadata {
-		out = append(out, key)
-	}
-	return out
-}
-
 var client tracing.HelloServiceClient
 
 func main() {
-	app := iris.Default()
-
-	tp, err := tracer.TracerProvider("http://localhost:14268/api/traces")
+	defaults := tracer.DefaultConfig()
+	defaults.MetricsAddr = "localhost:9465"
+	cfg, err := defaults.Load(os.Getenv(tracer.ConfigFileEnv))
 	if err != nil {
 		panic(err)
 	}
 
-	app.Use(ClientInterceptor(tp))
-
-	app.Get("/ping", Ping)
-
-	cc, err := grpc.Dial(
-		"localhost:50051",
-		grpc.WithInsecure(),
-	)
-
-	client = tracing.NewHelloServiceClient(cc)
-
-	app.Run(iris.Addr(":3030"))
-}
-
-// Ping -
-func Ping(ctx iris.Context) {
-
-	_, err := client.Pin(ctx.Request().Context(), &tracing.Request{Id: "123Adam"})
+	exp, stopMetrics, err := tracer.NewMetrics(cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	//go func(c context.Context) {
-	////tpp, err := tracer.TracerProvider("http://localhost:14268/api/traces")
-	////if err != nil {
-	////panic(err)
-	////}
+	tp, shutdown, err := tracer.New(cfg, tracer.WithMeterProvider(exp.MeterProvider()))
+	if err != nil {
+		panic(err)
+	}
 
-	//tr := otel.Tracer("ex.com/webserver")
+	cc, err := dial("localhost:50051", tp, exp.MeterProvider(), grpc.WithInsecure())
+	if err != nil {
+		panic(err)
+	}
 
-	//newCtx, span := tr.Start(c, "Sub operation...")
-	//ctx.ResetRequest(ctx.Request().WithContext(newCtx))
+	client = tracing.NewHelloServiceClient(cc)
 
-	//defer span.End()
+	app := newApp(tp, exp.MeterProvider())
+	logger := tracelog.New(os.Stdout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		if err := app.Run(iris.Addr(":3030"), iris.WithoutInterruptHandler, iris.WithoutServerError(iris.ErrServerClosed)); err != nil {
+			logger.Error(ctx, "failed to serve", "error", err)
+		}
+		cancel()
+	}()
+
+	stats, err := tracer.Graceful(ctx, time.Duration(cfg.ShutdownTimeout), shutdown,
+		app.Shutdown,
+		func(context.Context) error { return cc.Close() },
+		stopMetrics,
+	)
+	if err != nil {
+		logger.Error(ctx, "shutdown", "error", err)
+	}
+	logger.Info(ctx, "shutdown complete", "exported", stats.Exported, "dropped", stats.Dropped)
+}
 
-	//span.AddEvent("Sub span event adkjasklfjsdklfjl;ajfl;ak")
-	//}(ctx.Request().Context())
+// newApp returns the application serving /ping, tracing requests with tp
+// and recording their metrics with mp.
+func newApp(tp trace.TracerProvider, mp metric.MeterProvider) *iris.Application {
+	app := iris.Default()
 
-	//time.Sleep(time.Second * 1)
+	traced := iristrace.Middleware(
+		iristrace.WithTracerProvider(tp),
+		iristrace.WithMeterProvider(mp),
+		iristrace.WithFilter(iristrace.SkipHealthChecks),
+	)
+	app.Use(traced, iristrace.Recover())
+	// Use only runs for requests that matched a route; trace the others,
+	// e.g. 404s, through their error code handler.
+	app.OnAnyErrorCode(traced, func(ctx iris.Context) {
+		ctx.WriteString(http.StatusText(ctx.GetStatusCode()))
+	})
 
-	ctx.JSON(iris.Map{"response": "pong"})
+	app.Get("/ping", Ping)
 
+	return app
 }
 
-// ClientInterceptor -
-func ClientInterceptor(tp *tracesdk.TracerProvider) func(ctx iris.Context) {
-	return func(ctx iris.Context) {
-		req := ctx.Request()
-
-		//requestMetadata, _ := metadata.FromOutgoingContext(req.Context())
-		//metadataCopy := requestMetadata.Copy()
-
-		tr := otel.Tracer("ex.com/webserver")
-		newCtx, span := tr.Start(
-			req.Context(),
-			req.Host,
-			trace.WithSpanKind(trace.SpanKindClient),
-		)
-
-		//req = req.WithContext(newCtx)
-		ctx.ResetRequest(ctx.Request().WithContext(newCtx))
-
-		span.AddEvent("Nice operation!", trace.WithAttributes(attribute.Int("bogons", 100)))
-
-		defer span.End()
-
-		span.SetAttributes([]attribute.KeyValue{
-			{
-				Key:   attribute.Key("url"),
-				Value: attribute.StringValue(ctx.RemoteAddr()),
-			},
-			{
-				Key:   attribute.Key("method"),
-				Value: attribute.StringValue(req.Method),
-			},
-			{
-				Key:   attribute.Key("TraceID"),
-				Value: attribute.StringValue(span.SpanContext().TraceID().String()),
-			},
-			{
-				Key:   attribute.Key("statusCode"),
-				Value: attribute.IntValue(ctx.GetStatusCode()),
-			},
-		}...)
-
-		//Inject(req.Context(), &metadataCopy)
-		//newCtx = metadata.NewOutgoingContext(req.Context(), metadataCopy)
-
-		//ctx.ResetRequest(ctx.Request().WithContext(newCtx))
-
-		//func(ctx context.Context) {
-		//tpp, err := tracer.TracerProvider("http://localhost:14268/api/traces")
-		//if err != nil {
-		//panic(err)
-		//}
-
-		//tr := tpp.Tracer("helloAdam123456")
-		//var span trace.Span
-		//ctx, span = tr.Start(ctx, "Sub operation...")
-		//defer span.End()
-
-		//span.AddEvent("Sub span event adkjasklfjsdklfjl;ajfl;ak")
-		//}(ctx.Request().Context())
-
-		ctx.Next()
+// dial connects to the HelloService at target. Calls made with the context
+// of an incoming request continue its trace.
+func dial(target string, tp trace.TracerProvider, mp metric.MeterProvider, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	traceOpts := []grpctrace.Option{
+		grpctrace.WithTracerProvider(tp),
+		grpctrace.WithMeterProvider(mp),
+		grpctrace.WithLegacyAttributes(),
 	}
-}
-
-type Option func(*config)
-
-type config struct {
-	TracerProvider trace.TracerProvider
-	Propagators    propagation.TextMapPropagator
 
-	Tracer trace.Tracer
+	opts = append(opts,
+		grpc.WithUnaryInterceptor(grpctrace.UnaryClientInterceptor(traceOpts...)),
+		grpc.WithStreamInterceptor(grpctrace.StreamClientInterceptor(traceOpts...)),
+	)
+	return grpc.Dial(target, opts...)
 }
 
-func Inject(ctx context.Context, metadata *metadata.MD, opts ...Option) {
-	c := newConfig(opts...)
-	c.Propagators.Inject(ctx, &metadataSupplier{
-		metadata: metadata,
-	})
-}
+// Ping -
+func Ping(ctx iris.Context) {
 
-func newConfig(opts ...Option) config {
-	cfg := config{
-		Propagators:    otel.GetTextMapPropagator(),
-		TracerProvider: otel.GetTracerProvider(),
-	}
-	for _, opt := range opts {
-		opt(&cfg)
+	_, err := client.Pin(ctx.Request().Context(), &tracing.Request{Id: "123Adam"})
+	if err != nil {
+		// The gRPC client span holds the details of the failure.
+		iristrace.RecordError(ctx, err)
+		ctx.StatusCode(http.StatusBadGateway)
+		return
 	}
 
-	cfg.Tracer = cfg.TracerProvider.Tracer(
-		defaultTracerName,
-		trace.WithInstrumentationVersion(contrib.SemVersion()),
-	)
+	ctx.JSON(iris.Map{"response": "pong"})
 
-	return cfg
 }
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpc_codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"tracing/grpctrace"
+	"tracing/metrics"
+	tracing "tracing/proto"
+	"tracing/spantest"
+)
+
+type helloServer struct {
+	pin func(ctx context.Context, in *tracing.Request) (*tracing.Response, error)
+}
+
+func (s helloServer) Pin(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+	if s.pin == nil {
+		return &tracing.Response{Id: in.GetId()}, nil
+	}
+	return s.pin(ctx, in)
+}
+
+// startPing serves srv like the server binary does, points client at it and
+// returns the application serving /ping, with the routes setup adds, along
+// with the exporters receiving the spans and metrics of both.
+func startPing(t *testing.T, srv tracing.HelloServiceServer, setup ...func(*iris.Application)) (*tracetest.InMemoryExporter, *metrics.Exporter, http.Handler) {
+	t.Helper()
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exp))
+	prevPropagator, prevClient := otel.GetTextMapPropagator(), client
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(prevPropagator)
+		client = prevClient
+	})
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	metricsExp, err := metrics.NewExporter()
+	if err != nil {
+		t.Fatalf("metrics exporter: %v", err)
+	}
+	mp := metricsExp.MeterProvider()
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpctrace.UnaryServerInterceptor(grpctrace.WithTracerProvider(tp), grpctrace.WithMeterProvider(mp)),
+		grpctrace.UnaryRecoveryInterceptor(),
+	))
+	tracing.RegisterHelloServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := dial("bufnet", tp, mp,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	client = tracing.NewHelloServiceClient(cc)
+
+	app := newApp(tp, mp)
+	for _, f := range setup {
+		f(app)
+	}
+	if err := app.Build(); err != nil {
+		t.Fatalf("build app: %v", err)
+	}
+	return exp, metricsExp, app
+}
+
+func TestPingIsOneTrace(t *testing.T) {
+	exp, _, app := startPing(t, helloServer{})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /ping status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	find := func(name string, kind trace.SpanKind) *tracesdk.SpanSnapshot {
+		t.Helper()
+		for _, span := range spans {
+			if span.Name == name && span.SpanKind == kind {
+				return span
+			}
+		}
+		t.Fatalf("no %s span named %q", kind, name)
+		return nil
+	}
+	httpServer := find("GET /ping", trace.SpanKindServer)
+	grpcClient := find("tracing.HelloService/Pin", trace.SpanKindClient)
+	grpcServer := find("tracing.HelloService/Pin", trace.SpanKindServer)
+
+	traceID := httpServer.SpanContext.TraceID()
+	for _, span := range spans {
+		if got := span.SpanContext.TraceID(); got != traceID {
+			t.Errorf("%s %s span TraceID = %s, want %s", span.SpanKind, span.Name, got, traceID)
+		}
+	}
+	if got, want := grpcClient.Parent.SpanID(), httpServer.SpanContext.SpanID(); got != want {
+		t.Errorf("gRPC client parent = %s, want HTTP server span %s", got, want)
+	}
+	if got, want := grpcServer.Parent.SpanID(), grpcClient.SpanContext.SpanID(); got != want {
+		t.Errorf("gRPC server parent = %s, want gRPC client span %s", got, want)
+	}
+}
+
+func TestUnmatchedRoutesAreTraced(t *testing.T) {
+	exp, _, app := startPing(t, helloServer{})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pong", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /pong status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "GET" || spans[0].SpanKind != trace.SpanKindServer {
+		t.Fatalf("got spans %v, want one GET server span", spans)
+	}
+}
+
+func TestPingFailsOnRPCErrors(t *testing.T) {
+	exp, _, app := startPing(t, helloServer{pin: func(context.Context, *tracing.Request) (*tracing.Response, error) {
+		return nil, status.Error(grpc_codes.Unavailable, "out of pins")
+	}})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("GET /ping status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	for _, span := range spans {
+		if span.SpanKind == trace.SpanKindServer && span.Name != "GET /ping" {
+			// The gRPC server decides on its own span.
+			continue
+		}
+		if span.StatusCode != codes.Error {
+			t.Errorf("%s %s span status = %v, want Error", span.SpanKind, span.Name, span.StatusCode)
+		}
+		if span.Name == "GET /ping" && !hasEvent(span, semconv.ExceptionEventName) {
+			t.Errorf("%s span has no %s event", span.Name, semconv.ExceptionEventName)
+		}
+	}
+}
+
+// panics is a route whose handler panics, for the tests of Recover.
+func panics(app *iris.Application) {
+	app.Get("/panic", func(iris.Context) {
+		panic("out of pins")
+	})
+}
+
+func TestRecoversFromPanics(t *testing.T) {
+	exp, _, app := startPing(t, helloServer{}, panics)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("GET /panic status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].StatusCode != codes.Error {
+		t.Errorf("%s span status = %v, want Error", spans[0].Name, spans[0].StatusCode)
+	}
+	if !hasEvent(spans[0], semconv.ExceptionEventName) {
+		t.Errorf("%s span has no %s event", spans[0].Name, semconv.ExceptionEventName)
+	}
+}
+
+func TestPingGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		path   string
+		pin    func(context.Context, *tracing.Request) (*tracing.Response, error)
+	}{
+		{"ok", "testdata/ping.golden.json", "/ping", nil},
+		{"rpc error", "testdata/ping_error.golden.json", "/ping", func(context.Context, *tracing.Request) (*tracing.Response, error) {
+			return nil, status.Error(grpc_codes.Unavailable, "out of pins")
+		}},
+		{"panic", "testdata/panic.golden.json", "/panic", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, _, app := startPing(t, helloServer{pin: tt.pin}, panics)
+
+			app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			spantest.MatchGolden(t, tt.golden, exp.GetSpans(),
+				spantest.WithMaskedAttributes(semconv.ExceptionStacktraceKey),
+			)
+		})
+	}
+}
+
+func TestPingRecordsMetrics(t *testing.T) {
+	exp, metricsExp, app := startPing(t, helloServer{})
+
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	traceID := exp.GetSpans()[0].SpanContext.TraceID().String()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	rec := httptest.NewRecorder()
+	metricsExp.ServeHTTP(rec, req)
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`http_server_duration_bucket{http_method="GET",http_route="/ping",http_status_code="200"`,
+		`rpc_client_duration_bucket{rpc_grpc_status_code="0",rpc_method="Pin",rpc_service="tracing.HelloService",rpc_system="grpc"`,
+		`rpc_server_duration_bucket{rpc_grpc_status_code="0",rpc_method="Pin",rpc_service="tracing.HelloService",rpc_system="grpc"`,
+		`rpc_server_request_size_count{rpc_method="Pin",rpc_service="tracing.HelloService",rpc_system="grpc"} 1`,
+		`# {trace_id="` + traceID + `",span_id=`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape lacks %s:\n%s", want, body)
+		}
+	}
+}
+
+func hasEvent(span *tracesdk.SpanSnapshot, name string) bool {
+	for _, e := range span.MessageEvents {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package grpctrace
+
+import (
+	"go.opentelemetry.io/contrib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"tracing/baggagepolicy"
+	"tracing/metrics"
+	"tracing/redact"
+)
+
+const (
+	// instrumentationName is the name of the single tracer and meter every
+	// interceptor in this package creates its spans and metrics with.
+	instrumentationName = "tracing/grpctrace"
+)
+
+type config struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Propagators    propagation.TextMapPropagator
+	Baggage        *baggagepolicy.Policy
+	Payload        payloadConfig
+
+	Status  statusClassifiers
+	Filters []Filter
+
+	// LegacyAttributes also records the attributes used before the
+	// semantic conventions were followed.
+	LegacyAttributes bool
+
+	Tracer        trace.Tracer
+	ClientMetrics rpcMetrics
+	ServerMetrics rpcMetrics
+}
+
+func newConfig(opts ...Option) config {
+	cfg := config{
+		Propagators:    otel.GetTextMapPropagator(),
+		TracerProvider: otel.GetTracerProvider(),
+		MeterProvider:  global.GetMeterProvider(),
+		Payload: payloadConfig{
+			Capture: PayloadCaptureRequest,
+			Limit:   defaultPayloadLimit,
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	cfg.Tracer = cfg.TracerProvider.Tracer(
+		instrumentationName,
+		trace.WithInstrumentationVersion(contrib.SemVersion()),
+	)
+	meter := metrics.NewMeter(cfg.MeterProvider, instrumentationName)
+	cfg.ClientMetrics = newRPCMetrics(meter, trace.SpanKindClient)
+	cfg.ServerMetrics = newRPCMetrics(meter, trace.SpanKindServer)
+
+	return cfg
+}
+
+// Option specifies instrumentation configuration options.
+type Option func(*config)
+
+// WithTracerProvider specifies a tracer provider to use for creating a tracer.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.TracerProvider = provider
+	}
+}
+
+// WithMeterProvider specifies a meter provider to use for recording the
+// rpc.client.* and rpc.server.* metrics of traced RPCs. If none is
+// specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(cfg *config) {
+		cfg.MeterProvider = provider
+	}
+}
+
+// WithPropagators specifies propagators to use for extracting
+// information from the HTTP requests. If none are specified, global
+// ones will be used.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return func(cfg *config) {
+		cfg.Propagators = propagators
+	}
+}
+
+// WithBaggagePolicy specifies the policy filtering the baggage servers
+// accept from their callers and copying baggage members onto the spans of
+// servers and clients. By default all baggage is accepted and none is
+// recorded on spans.
+func WithBaggagePolicy(p *baggagepolicy.Policy) Option {
+	return func(cfg *config) {
+		cfg.Baggage = p
+	}
+}
+
+// WithPayloadCapture specifies which request/response payloads are recorded
+// on unary RPC spans. The default is PayloadCaptureRequest.
+func WithPayloadCapture(capture PayloadCapture) Option {
+	return func(cfg *config) {
+		cfg.Payload.Capture = capture
+	}
+}
+
+// WithPayloadLimit specifies the maximum number of bytes recorded per
+// payload; longer payloads are truncated and marked as such. A limit <= 0
+// records payloads in full.
+func WithPayloadLimit(limit int) Option {
+	return func(cfg *config) {
+		cfg.Payload.Limit = limit
+	}
+}
+
+// WithPayloadMethods restricts payload capture to the given full methods
+// ("/tracing.HelloService/Pin") or services ("/tracing.HelloService/").
+func WithPayloadMethods(methods ...string) Option {
+	return func(cfg *config) {
+		cfg.Payload.Allow = append(cfg.Payload.Allow, methods...)
+	}
+}
+
+// WithoutPayloadMethods disables payload capture for the given full methods
+// or services, even if they are allowed by WithPayloadMethods.
+func WithoutPayloadMethods(methods ...string) Option {
+	return func(cfg *config) {
+		cfg.Payload.Deny = append(cfg.Payload.Deny, methods...)
+	}
+}
+
+// WithRedactor specifies the redactor applied to captured payloads. If none
+// is specified, the global one registered with redact.SetGlobal when the
+// payload is recorded is used.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(cfg *config) {
+		cfg.Payload.Redactor = r
+		cfg.Payload.RedactorSet = true
+	}
+}
+
+// WithLegacyAttributes also records the attribute keys used before spans
+// followed the RPC semantic conventions: "statusCode" next to
+// rpc.grpc.status_code, "TraceID" on client spans, and "request" and
+// "response" next to the payload attributes. Use it while dashboards still
+// query the old keys, and drop it once they have moved to the standard ones:
+// the legacy keys double the size of those attributes.
+func WithLegacyAttributes() Option {
+	return func(cfg *config) {
+		cfg.LegacyAttributes = true
+		cfg.Payload.Legacy = true
+	}
+}
+
+// WithStatusClassifier specifies which gRPC codes mark spans of kind as
+// failed. By default client spans fail for every code but OK and server
+// spans as described by ServerStatusClassifier.
+func WithStatusClassifier(kind trace.SpanKind, classify StatusClassifier) Option {
+	return func(cfg *config) {
+		if cfg.Status.byKind == nil {
+			cfg.Status.byKind = map[trace.SpanKind]StatusClassifier{}
+		}
+		cfg.Status.byKind[kind] = classify
+	}
+}
+
+// WithMethodStatusClassifier overrides the StatusClassifier of spans of
+// kind for fullMethod, or for every method of a service when fullMethod
+// ends in "/" (e.g. "/tracing.HelloService/"). Later overrides win.
+func WithMethodStatusClassifier(fullMethod string, kind trace.SpanKind, classify StatusClassifier) Option {
+	return func(cfg *config) {
+		cfg.Status.byMethod = append(cfg.Status.byMethod, methodClassifier{
+			method:   fullMethod,
+			kind:     kind,
+			classify: classify,
+		})
+	}
+}
+
+// WithFilter specifies filters deciding which RPCs are traced; an RPC is
+// traced if every filter returns true. By default every RPC is traced.
+func WithFilter(filters ...Filter) Option {
+	return func(cfg *config) {
+		cfg.Filters = append(cfg.Filters, filters...)
+	}
+}
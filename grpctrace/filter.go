@@ -0,0 +1,52 @@
+package grpctrace
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// InterceptorInfo describes an RPC to a Filter.
+type InterceptorInfo struct {
+	// FullMethod is the method called, e.g. /tracing.HelloService/Pin.
+	FullMethod string
+	// Metadata is the incoming metadata on the server and the outgoing
+	// metadata on the client.
+	Metadata metadata.MD
+	// Peer is the address of the client on the server and the dial target
+	// on the client, where NewClientHandler leaves it empty.
+	Peer string
+}
+
+// Filter reports whether the RPC described by info is traced.
+type Filter func(info *InterceptorInfo) bool
+
+var (
+	// SkipHealthChecks leaves the gRPC health checking service untraced.
+	SkipHealthChecks = SkipMethods("/grpc.health.v1.Health/")
+
+	// SkipReflection leaves every version of the gRPC server reflection
+	// service untraced.
+	SkipReflection Filter = func(info *InterceptorInfo) bool {
+		return !strings.HasPrefix(info.FullMethod, "/grpc.reflection.")
+	}
+)
+
+// SkipMethods returns a Filter leaving the given methods untraced. A
+// method ending in "/" names every method of a service, e.g.
+// "/tracing.HelloService/".
+func SkipMethods(fullMethods ...string) Filter {
+	return func(info *InterceptorInfo) bool {
+		return !matchMethod(fullMethods, info.FullMethod)
+	}
+}
+
+// traced reports whether every filter of cfg lets the RPC through.
+func (cfg *config) traced(info *InterceptorInfo) bool {
+	for _, f := range cfg.Filters {
+		if !f(info) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package grpctrace
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryClientInterceptor returns a grpc.UnaryClientInterceptor suitable
+// for use in a grpc.Dial call.
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	cfg := newConfig(opts...)
+
+	return func(ctx context.Context, method string, req, resp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		requestMetadata, _ := metadata.FromOutgoingContext(ctx)
+		if !cfg.traced(&InterceptorInfo{FullMethod: method, Metadata: requestMetadata, Peer: cc.Target()}) {
+			return invoker(ctx, method, req, resp, cc, callOpts...)
+		}
+		metadataCopy := requestMetadata.Copy()
+
+		name, attr := spanInfo(method, cc.Target())
+		attr = append(attr, deadlineAttr(ctx)...)
+		attr = append(attr, cfg.Baggage.Attributes(ctx)...)
+
+		// The span derives from the caller's ctx so it keeps the parent span,
+		// deadline and cancellation, which are handed on to invoker below.
+		var span trace.Span
+		ctx, span = cfg.Tracer.Start(
+			ctx,
+			name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attr...),
+		)
+
+		span.SetAttributes(cfg.traceIDAttrs(span)...)
+		defer span.End()
+
+		cfg.Propagators.Inject(ctx, &metadataSupplier{
+			metadata: &metadataCopy,
+		})
+		ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
+
+		metricAttr := metricAttrs(attr)
+		start := time.Now()
+
+		messageSent.event(span, 1, req)
+		cfg.ClientMetrics.request(ctx, metricAttr, req)
+
+		err := invoker(ctx, method, req, resp, cc, callOpts...)
+		if err == nil {
+			messageReceived.event(span, 1, resp)
+			cfg.ClientMetrics.response(ctx, metricAttr, resp)
+		}
+		span.SetAttributes(cfg.Payload.attributes(method, req, resp, err)...)
+		code := cfg.setStatus(span, trace.SpanKindClient, method, err)
+		cfg.ClientMetrics.finish(ctx, metricAttr, time.Since(start), code)
+
+		return err
+	}
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor suitable
+// for use in a grpc.NewServer call.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(opts...)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		requestMetadata, _ := metadata.FromIncomingContext(ctx)
+		if !cfg.traced(&InterceptorInfo{FullMethod: info.FullMethod, Metadata: requestMetadata, Peer: peerFromCtx(ctx)}) {
+			return handler(ctx, req)
+		}
+		metadataCopy := requestMetadata.Copy()
+
+		entries, spanCtx := extract(ctx, &metadataCopy, cfg.Propagators)
+		ctx = baggage.ContextWithValues(ctx, cfg.Baggage.Filter(entries, peerFromCtx(ctx))...)
+
+		name, attr := spanInfo(info.FullMethod, peerFromCtx(ctx))
+		attr = append(attr, cfg.Baggage.Attributes(ctx)...)
+
+		ctx, span := cfg.Tracer.Start(
+			trace.ContextWithRemoteSpanContext(ctx, spanCtx),
+			name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attr...),
+		)
+		defer span.End()
+
+		metricAttr := metricAttrs(attr)
+		start := time.Now()
+
+		messageReceived.event(span, 1, req)
+		cfg.ServerMetrics.request(ctx, metricAttr, req)
+
+		resp, err := handler(ctx, req)
+		if err == nil {
+			messageSent.event(span, 1, resp)
+			cfg.ServerMetrics.response(ctx, metricAttr, resp)
+		}
+		span.SetAttributes(cfg.Payload.attributes(info.FullMethod, req, resp, err)...)
+		code := cfg.setStatus(span, trace.SpanKindServer, info.FullMethod, err)
+		cfg.ServerMetrics.finish(ctx, metricAttr, time.Since(start), code)
+
+		return resp, err
+	}
+}
+
+func spanInfo(fullMethod, peerAddress string) (string, []attribute.KeyValue) {
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	name, mAttrs := parseFullMethod(fullMethod)
+	attrs = append(attrs, mAttrs...)
+	attrs = append(attrs, peerAttr(peerAddress)...)
+
+	return name, attrs
+}
+
+func parseFullMethod(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.TrimLeft(fullMethod, "/")
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		// Invalid format, does not follow `/package.service/method`.
+		return name, []attribute.KeyValue(nil)
+	}
+
+	var attrs []attribute.KeyValue
+	if service := parts[0]; service != "" {
+		attrs = append(attrs, semconv.RPCServiceKey.String(service))
+	}
+	if method := parts[1]; method != "" {
+		attrs = append(attrs, semconv.RPCMethodKey.String(method))
+	}
+	return name, attrs
+}
+
+// peerAttr describes the other end of an RPC: net.peer.ip when addr holds
+// an IP address, net.peer.name when it holds a host name, and
+// net.peer.port. addr may be a dial target with a resolver scheme, e.g.
+// "dns:///localhost:50051". Nothing is guessed for parts addr lacks.
+func peerAttr(addr string) []attribute.KeyValue {
+	if strings.HasPrefix(addr, "unix:") {
+		return nil
+	}
+	if i := strings.LastIndex(addr, "/"); i >= 0 {
+		addr = addr[i+1:]
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port, e.g. a target resolved by name only.
+		host, port = addr, ""
+	}
+
+	var attrs []attribute.KeyValue
+	if host != "" {
+		if net.ParseIP(host) != nil {
+			attrs = append(attrs, semconv.NetPeerIPKey.String(host))
+		} else {
+			attrs = append(attrs, semconv.NetPeerNameKey.String(host))
+		}
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(p))
+	}
+
+	return attrs
+}
+
+func peerFromCtx(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+// deadlineAttr records how much time the caller left for the RPC, if it set
+// a deadline at all.
+func deadlineAttr(ctx context.Context) []attribute.KeyValue {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		deadlineRemainingKey.Int64(time.Until(deadline).Milliseconds()),
+	}
+}
@@ -0,0 +1,476 @@
+package grpctrace
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpc_codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"tracing/baggagepolicy"
+	tracing "tracing/proto"
+	"tracing/spantest"
+)
+
+type helloServer struct {
+	pin func(ctx context.Context, in *tracing.Request) (*tracing.Response, error)
+}
+
+func (s *helloServer) Pin(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+	return s.pin(ctx, in)
+}
+
+// startServer serves srv on an in-process bufconn listener and returns a
+// client connection dialed through it.
+func startServer(t *testing.T, srv tracing.HelloServiceServer, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	s := grpc.NewServer(serverOpts...)
+	tracing.RegisterHelloServiceServer(s, srv)
+	return dialServer(t, s, dialOpts...)
+}
+
+// dialServer serves s on an in-process bufconn listener and returns a
+// client connection dialed through it.
+func dialServer(t *testing.T, s *grpc.Server, dialOpts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	dialOpts = append(dialOpts,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+	)
+	cc, err := grpc.Dial("bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("dial bufnet: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+
+	return cc
+}
+
+func TestUnaryInterceptorsContinueTrace(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exp))
+	opts := []Option{
+		WithTracerProvider(tp),
+		WithPropagators(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})),
+	}
+
+	var gotUser string
+	srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+		gotUser = baggage.Value(ctx, "username").AsString()
+		return &tracing.Response{Id: in.GetId()}, nil
+	}}
+
+	cc := startServer(t, srv,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor(opts...))},
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+	)
+
+	ctx := baggage.ContextWithValues(context.Background(), attribute.String("username", "donuts"))
+	if _, err := tracing.NewHelloServiceClient(cc).Pin(ctx, &tracing.Request{Id: "123"}); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	if gotUser != "donuts" {
+		t.Errorf("baggage username in handler = %q, want %q", gotUser, "donuts")
+	}
+
+	var client, server *tracesdk.SpanSnapshot
+	for _, s := range exp.GetSpans() {
+		switch s.SpanKind {
+		case trace.SpanKindClient:
+			client = s
+		case trace.SpanKindServer:
+			server = s
+		}
+	}
+	if client == nil || server == nil {
+		t.Fatalf("want one client and one server span, got %d spans", len(exp.GetSpans()))
+	}
+
+	if client.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Errorf("server TraceID = %s, want client TraceID %s", server.SpanContext.TraceID(), client.SpanContext.TraceID())
+	}
+	if server.Parent.SpanID() != client.SpanContext.SpanID() {
+		t.Errorf("server parent SpanID = %s, want client SpanID %s", server.Parent.SpanID(), client.SpanContext.SpanID())
+	}
+	if !server.Parent.IsRemote() {
+		t.Error("server parent should be remote")
+	}
+}
+
+func TestUnaryInterceptorSpans(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   grpc_codes.Code
+		wantClient codes.Code
+	}{
+		{"ok", nil, grpc_codes.OK, codes.Unset},
+		{"not found", status.Error(grpc_codes.NotFound, "no such pin"), grpc_codes.NotFound, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := spantest.New(t)
+			opts := []Option{WithTracerProvider(rec.TracerProvider()), WithPropagators(propagation.TraceContext{})}
+
+			srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &tracing.Response{Id: in.GetId()}, nil
+			}}
+			cc := startServer(t, srv,
+				[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor(opts...))},
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+			)
+
+			ctx, parent := rec.TracerProvider().Tracer("test").Start(context.Background(), "parent")
+			_, err := tracing.NewHelloServiceClient(cc).Pin(ctx, &tracing.Request{Id: "123"})
+			parent.End()
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Pin error = %v, want %v", err, tt.wantCode)
+			}
+
+			rec.Len(3)
+			client := rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindClient).
+				HasParent(rec.Span("parent")).
+				HasAttribute(semconv.RPCSystemGRPC).
+				HasAttribute(semconv.RPCServiceKey.String("tracing.HelloService")).
+				HasAttribute(semconv.RPCMethodKey.String("Pin")).
+				HasAttribute(semconv.NetPeerNameKey.String("bufnet")).
+				HasAttribute(grpcStatusCodeKey.Int64(int64(tt.wantCode))).
+				HasStatus(tt.wantClient).
+				HasEvent("message", semconv.RPCMessageTypeSent, semconv.RPCMessageIDKey.Int(1))
+			server := rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindServer).
+				HasParent(client).
+				HasRemoteParent().
+				HasAttribute(semconv.RPCSystemGRPC).
+				HasAttribute(grpcStatusCodeKey.Int64(int64(tt.wantCode))).
+				HasStatus(codes.Unset).
+				HasEvent("message", semconv.RPCMessageTypeReceived, semconv.RPCMessageIDKey.Int(1))
+			if tt.err == nil {
+				client.HasEvent("message", semconv.RPCMessageTypeReceived)
+				server.HasEvent("message", semconv.RPCMessageTypeSent)
+			} else {
+				client.HasStatusMessage("no such pin")
+			}
+		})
+	}
+}
+
+func TestUnaryClientDeadline(t *testing.T) {
+	rec := spantest.New(t)
+	srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+		return &tracing.Response{Id: in.GetId()}, nil
+	}}
+
+	// invoked records the context the traced interceptor hands on.
+	var invoked context.Context
+	record := func(ctx context.Context, method string, req, resp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		invoked = ctx
+		return invoker(ctx, method, req, resp, cc, opts...)
+	}
+	cc := startServer(t, srv, nil,
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(WithTracerProvider(rec.TracerProvider())), record),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	if _, err := tracing.NewHelloServiceClient(cc).Pin(ctx, &tracing.Request{}); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	if got, ok := invoked.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("invoker deadline = %v, %v, want %v", got, ok, want)
+	}
+
+	span := rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindClient).Snapshot()
+	var remaining int64 = -1
+	for _, kv := range span.Attributes {
+		if kv.Key == deadlineRemainingKey {
+			remaining = kv.Value.AsInt64()
+		}
+	}
+	if remaining <= 0 || remaining > time.Minute.Milliseconds() {
+		t.Errorf("%s = %d, want within (0, %d]", deadlineRemainingKey, remaining, time.Minute.Milliseconds())
+	}
+
+	// Without a deadline there is nothing to record.
+	rec.Reset()
+	if _, err := tracing.NewHelloServiceClient(cc).Pin(context.Background(), &tracing.Request{}); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindClient).HasNoAttribute(deadlineRemainingKey)
+}
+
+func TestPeerAttr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want []attribute.KeyValue
+	}{
+		{"127.0.0.1:50051", []attribute.KeyValue{semconv.NetPeerIPKey.String("127.0.0.1"), semconv.NetPeerPortKey.Int(50051)}},
+		{"[::1]:443", []attribute.KeyValue{semconv.NetPeerIPKey.String("::1"), semconv.NetPeerPortKey.Int(443)}},
+		{"localhost:50051", []attribute.KeyValue{semconv.NetPeerNameKey.String("localhost"), semconv.NetPeerPortKey.Int(50051)}},
+		{"dns:///example.com:443", []attribute.KeyValue{semconv.NetPeerNameKey.String("example.com"), semconv.NetPeerPortKey.Int(443)}},
+		{":50051", []attribute.KeyValue{semconv.NetPeerPortKey.Int(50051)}},
+		{"bufnet", []attribute.KeyValue{semconv.NetPeerNameKey.String("bufnet")}},
+		{"unix:///tmp/grpc.sock", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := peerAttr(tt.addr)
+		if len(got) != len(tt.want) {
+			t.Errorf("peerAttr(%q) = %v, want %v", tt.addr, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("peerAttr(%q) = %v, want %v", tt.addr, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStatusClassification(t *testing.T) {
+	notFound := &helloServer{pin: func(context.Context, *tracing.Request) (*tracing.Response, error) {
+		return nil, status.Error(grpc_codes.NotFound, "no such pin")
+	}}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantServer codes.Code
+	}{
+		{"default", nil, codes.Unset},
+		{"method override", []Option{WithMethodStatusClassifier("/tracing.HelloService/Pin", trace.SpanKindServer, ErrorCodes(grpc_codes.NotFound))}, codes.Error},
+		{"other method override", []Option{WithMethodStatusClassifier("/tracing.OtherService/", trace.SpanKindServer, ErrorCodes(grpc_codes.NotFound))}, codes.Unset},
+		{"kind override", []Option{WithStatusClassifier(trace.SpanKindServer, ClientStatusClassifier)}, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := tracetest.NewInMemoryExporter()
+			opts := append([]Option{WithTracerProvider(tracesdk.NewTracerProvider(tracesdk.WithSyncer(exp)))}, tt.opts...)
+
+			cc := startServer(t, notFound,
+				[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor(opts...))},
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+			)
+			if _, err := tracing.NewHelloServiceClient(cc).Pin(context.Background(), &tracing.Request{}); status.Code(err) != grpc_codes.NotFound {
+				t.Fatalf("Pin error = %v, want NotFound", err)
+			}
+
+			for _, span := range exp.GetSpans() {
+				want := codes.Error
+				if span.SpanKind == trace.SpanKindServer {
+					want = tt.wantServer
+				}
+				if span.StatusCode != want {
+					t.Errorf("%s span status = %v, want %v", span.SpanKind, span.StatusCode, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+		return &tracing.Response{Id: in.GetId()}, nil
+	}}
+	noTrace := func(info *InterceptorInfo) bool {
+		return len(info.Metadata.Get("x-no-trace")) == 0
+	}
+	// The client sees the dial target, the server the bufconn address.
+	serverOnly := func(info *InterceptorInfo) bool {
+		return info.Peer != "bufnet"
+	}
+
+	const (
+		pinSpan        = "tracing.HelloService/Pin"
+		healthSpan     = "grpc.health.v1.Health/Check"
+		reflectionSpan = "grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+	)
+	tests := []struct {
+		name    string
+		filters []Filter
+		md      metadata.MD
+		// want maps span names to the number of spans expected with them.
+		want map[string]int
+	}{
+		{"no filter", nil, nil, map[string]int{pinSpan: 2, healthSpan: 2, reflectionSpan: 2}},
+		{"other method", []Filter{SkipMethods("/tracing.HelloService/Other")}, nil, map[string]int{pinSpan: 2, healthSpan: 2, reflectionSpan: 2}},
+		{"method", []Filter{SkipMethods("/tracing.HelloService/Pin")}, nil, map[string]int{healthSpan: 2, reflectionSpan: 2}},
+		{"service", []Filter{SkipMethods("/tracing.HelloService/")}, nil, map[string]int{healthSpan: 2, reflectionSpan: 2}},
+		{"health checks", []Filter{SkipHealthChecks}, nil, map[string]int{pinSpan: 2, reflectionSpan: 2}},
+		{"reflection", []Filter{SkipReflection}, nil, map[string]int{pinSpan: 2, healthSpan: 2}},
+		{"health checks and reflection", []Filter{SkipHealthChecks, SkipReflection}, nil, map[string]int{pinSpan: 2}},
+		{"metadata absent", []Filter{noTrace}, nil, map[string]int{pinSpan: 2, healthSpan: 2, reflectionSpan: 2}},
+		{"metadata present", []Filter{noTrace}, metadata.Pairs("x-no-trace", "1"), map[string]int{}},
+		{"peer", []Filter{serverOnly}, nil, map[string]int{pinSpan: 1, healthSpan: 1, reflectionSpan: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := spantest.New(t)
+			opts := []Option{WithTracerProvider(rec.TracerProvider())}
+			for _, f := range tt.filters {
+				opts = append(opts, WithFilter(f))
+			}
+
+			s := grpc.NewServer(
+				grpc.UnaryInterceptor(UnaryServerInterceptor(opts...)),
+				grpc.StreamInterceptor(StreamServerInterceptor(opts...)),
+			)
+			tracing.RegisterHelloServiceServer(s, srv)
+			healthpb.RegisterHealthServer(s, health.NewServer())
+			reflection.Register(s)
+			cc := dialServer(t, s,
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+				grpc.WithStreamInterceptor(StreamClientInterceptor(opts...)),
+			)
+
+			ctx := metadata.NewOutgoingContext(context.Background(), tt.md)
+			if _, err := tracing.NewHelloServiceClient(cc).Pin(ctx, &tracing.Request{}); err != nil {
+				t.Fatalf("Pin: %v", err)
+			}
+			if _, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			listServices(t, ctx, cc)
+
+			total := 0
+			for _, n := range tt.want {
+				total += n
+			}
+			waitForSpans(t, rec, total)
+			got := map[string]int{}
+			for _, span := range rec.Spans() {
+				got[span.Name]++
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spans = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// listServices lists the services of cc through the server reflection
+// service.
+func listServices(t *testing.T, ctx context.Context, cc *grpc.ClientConn) {
+	t.Helper()
+
+	stream, err := reflectionpb.NewServerReflectionClient(cc).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	req := &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}
+	if err := stream.Send(req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv = %v, want io.EOF", err)
+	}
+}
+
+func TestBaggagePolicy(t *testing.T) {
+	propagators := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	baggageCtx := baggage.ContextWithValues(context.Background(),
+		attribute.String("username", "donuts"),
+		attribute.String("session", "secret"),
+	)
+
+	tests := []struct {
+		name      string
+		networks  []string
+		wantUser  string
+		wantSpans int
+	}{
+		{name: "trusted", wantUser: "donuts", wantSpans: 2},
+		// bufconn peers have no IP address, so they are outside any network.
+		{name: "untrusted", networks: []string{"10.0.0.0/8"}, wantSpans: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks, err := baggagepolicy.ParseNetworks(tt.networks...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			policy := baggagepolicy.New(
+				baggagepolicy.WithAllowedKeys("username"),
+				baggagepolicy.WithTrustedNetworks(networks...),
+				baggagepolicy.WithPromotedKeys("username"),
+			)
+
+			exp := tracetest.NewInMemoryExporter()
+			tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exp))
+			opts := []Option{WithTracerProvider(tp), WithPropagators(propagators), WithBaggagePolicy(policy)}
+
+			var gotUser, gotSession string
+			srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+				gotUser = baggage.Value(ctx, "username").AsString()
+				gotSession = baggage.Value(ctx, "session").AsString()
+				return &tracing.Response{Id: in.GetId()}, nil
+			}}
+			cc := startServer(t, srv,
+				[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor(opts...))},
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+			)
+			if _, err := tracing.NewHelloServiceClient(cc).Pin(baggageCtx, &tracing.Request{Id: "123"}); err != nil {
+				t.Fatalf("Pin: %v", err)
+			}
+
+			if gotUser != tt.wantUser || gotSession != "" {
+				t.Errorf("baggage in handler = username %q, session %q, want username %q only", gotUser, gotSession, tt.wantUser)
+			}
+			// The client span always records the username of its own
+			// baggage; the server span only when it was accepted.
+			var promoted int
+			for _, s := range exp.GetSpans() {
+				if hasAttr(s.Attributes, attribute.String("username", "donuts")) {
+					promoted++
+				}
+				if hasKey(s.Attributes, "session") {
+					t.Errorf("span %s records the session, which is not promoted", s.Name)
+				}
+			}
+			if promoted != tt.wantSpans {
+				t.Errorf("%d spans record the username, want %d", promoted, tt.wantSpans)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package grpctrace
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataSupplier carries trace context and baggage in gRPC metadata.
+// Keys are case-insensitive. A key with several values, e.g. tracestate or
+// baggage split over several entries, reads as the values joined with
+// commas, as HTTP joins repeated W3C headers. Binary "-bin" keys, such as
+// the grpc-trace-bin key of the OpenCensus binary format, read as their
+// first value, unjoined.
+type metadataSupplier struct {
+	metadata *metadata.MD
+}
+
+// assert that metadataSupplier implements the TextMapCarrier interface
+var _ propagation.TextMapCarrier = &metadataSupplier{}
+
+func (s *metadataSupplier) Get(key string) string {
+	values := s.values(key)
+	if len(values) == 0 {
+		return ""
+	}
+	if isBinaryKey(key) {
+		return values[0]
+	}
+	return strings.Join(values, ",")
+}
+
+// values returns the values of key, matching it case-insensitively.
+// metadata.MD lowercases keys it sets, but an MD may also be built
+// directly with keys of any case; the values of the lowercase key come
+// first.
+func (s *metadataSupplier) values(key string) []string {
+	key = strings.ToLower(key)
+	values := (*s.metadata)[key]
+
+	var others []string
+	for k := range *s.metadata {
+		if k != key && strings.EqualFold(k, key) {
+			others = append(others, k)
+		}
+	}
+	if len(others) == 0 {
+		return values
+	}
+	sort.Strings(others)
+	// Never append to the slice held by the metadata.
+	values = values[:len(values):len(values)]
+	for _, k := range others {
+		values = append(values, (*s.metadata)[k]...)
+	}
+	return values
+}
+
+// Set replaces every value of key, whatever the case it was set in.
+func (s *metadataSupplier) Set(key string, value string) {
+	for k := range *s.metadata {
+		if strings.EqualFold(k, key) {
+			delete(*s.metadata, k)
+		}
+	}
+	s.metadata.Set(key, value)
+}
+
+// Keys returns the keys of the metadata, lowercased.
+func (s *metadataSupplier) Keys() []string {
+	out := make([]string, 0, len(*s.metadata))
+	seen := make(map[string]bool, len(*s.metadata))
+	for key := range *s.metadata {
+		key = strings.ToLower(key)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+func isBinaryKey(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), metadataBinarySuffix)
+}
+
+// metadataBinarySuffix ends the keys of metadata with binary values.
+const metadataBinarySuffix = "-bin"
+
+// Inject injects correlation context and span context into the gRPC
+// metadata object. This function is meant to be used on outgoing
+// requests.
+func Inject(ctx context.Context, metadata *metadata.MD, opts ...Option) {
+	propagatorsOf(opts).Inject(ctx, &metadataSupplier{
+		metadata: metadata,
+	})
+}
+
+// Extract returns the correlation context and span context that
+// another service encoded in the gRPC metadata object with Inject.
+// This function is meant to be used on incoming requests.
+func Extract(ctx context.Context, metadata *metadata.MD, opts ...Option) ([]attribute.KeyValue, trace.SpanContext) {
+	return extract(ctx, metadata, propagatorsOf(opts))
+}
+
+func extract(ctx context.Context, metadata *metadata.MD, propagators propagation.TextMapPropagator) ([]attribute.KeyValue, trace.SpanContext) {
+	ctx = propagators.Extract(ctx, &metadataSupplier{
+		metadata: metadata,
+	})
+
+	attributeSet := baggage.Set(ctx)
+
+	return (&attributeSet).ToSlice(), trace.SpanContextFromContext(ctx)
+}
+
+// propagatorsOf returns the propagators opts specify, without building the
+// tracer and instruments of a full config.
+func propagatorsOf(opts []Option) propagation.TextMapPropagator {
+	cfg := config{Propagators: otel.GetTextMapPropagator()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg.Propagators
+}
@@ -0,0 +1,62 @@
+//go:build go1.18
+// +build go1.18
+
+package grpctrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	"tracing/propagators"
+)
+
+func FuzzMetadataSupplier(f *testing.F) {
+	f.Add("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01", "tracestate", "a=1")
+	f.Add("Baggage", "user=adam", "BAGGAGE", "tenant=acme")
+	f.Add("grpc-trace-bin", "\x00\x00\x01", "X-B3-TraceId", "0102")
+
+	f.Fuzz(func(t *testing.T, k1, v1, k2, v2 string) {
+		if !isASCII(k1) || !isASCII(k2) {
+			// gRPC metadata keys are ASCII; case folding elsewhere is
+			// not symmetric.
+			t.Skip()
+		}
+		md := metadata.MD{}
+		s := &metadataSupplier{metadata: &md}
+		s.Set(k1, v1)
+		s.Set(k2, v2)
+
+		if got := s.Get(k2); got != v2 {
+			t.Errorf("Get(%q) = %q after Set(%q, %q)", k2, got, k2, v2)
+		}
+		if got := s.Get(strings.ToUpper(k2)); got != v2 {
+			t.Errorf("Get(%q) = %q, want %q", strings.ToUpper(k2), got, v2)
+		}
+		if !strings.EqualFold(k1, k2) {
+			if got := s.Get(k1); got != v1 {
+				t.Errorf("Get(%q) = %q after Set(%q, %q)", k1, got, k1, v1)
+			}
+		}
+		for _, key := range s.Keys() {
+			if key != strings.ToLower(key) {
+				t.Errorf("Keys() has %q, want lowercase keys", key)
+			}
+		}
+
+		// Extraction must not panic, whatever the metadata holds.
+		extract, _ := propagators.Composite([]string{propagators.TraceContext}, propagators.Names())
+		Extract(context.Background(), &md, WithPropagators(extract))
+	})
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package grpctrace
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"tracing/propagators"
+)
+
+func TestPropagatorsRoundTripThroughMetadata(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x60, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), sc)
+
+	for _, name := range []string{propagators.TraceContext, propagators.B3, propagators.B3Multi, propagators.Jaeger, propagators.XRay, propagators.OpenCensus} {
+		t.Run(name, func(t *testing.T) {
+			inject, err := propagators.Composite([]string{name}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			extract, err := propagators.Composite([]string{propagators.TraceContext}, propagators.Names())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			md := metadata.MD{}
+			Inject(ctx, &md, WithPropagators(inject))
+			_, got := Extract(context.Background(), &md, WithPropagators(extract))
+			if got.TraceID() != sc.TraceID() || got.SpanID() != sc.SpanID() || !got.IsSampled() {
+				t.Errorf("extracted %v from %v, want %v", got, md, sc)
+			}
+		})
+	}
+}
+
+func TestMetadataSupplierJoinsValues(t *testing.T) {
+	md := metadata.MD{
+		"traceparent": {"00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"},
+		"tracestate":  {"a=1", "b=2"},
+		"Baggage":     {"user=adam"},
+		"baggage":     {"tenant=acme"},
+	}
+	s := &metadataSupplier{metadata: &md}
+
+	if got := s.Get("TraceState"); got != "a=1,b=2" {
+		t.Errorf("Get(TraceState) = %q, want a=1,b=2", got)
+	}
+
+	ctx := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}).Extract(context.Background(), s)
+	if got := trace.SpanContextFromContext(ctx).TraceState().Get("b").AsString(); got != "2" {
+		t.Errorf("tracestate b = %q, want 2", got)
+	}
+	// Both the lowercase and the mixed case entries are read.
+	for key, want := range map[string]string{"user": "adam", "tenant": "acme"} {
+		if got := baggage.Value(ctx, attribute.Key(key)).AsString(); got != want {
+			t.Errorf("baggage %s = %q, want %q", key, got, want)
+		}
+	}
+
+	s.Set("BAGGAGE", "user=eve")
+	if got := md["baggage"]; len(got) != 1 || got[0] != "user=eve" || len(md) != 3 {
+		t.Errorf("after Set, metadata = %v, want a single baggage value", md)
+	}
+}
+
+func TestMetadataSupplierBinaryKeys(t *testing.T) {
+	md := metadata.MD{"grpc-trace-bin": {"\x00\x01,", "second"}}
+	s := &metadataSupplier{metadata: &md}
+	if got := s.Get("Grpc-Trace-Bin"); got != "\x00\x01," {
+		t.Errorf("Get(grpc-trace-bin) = %q, want the first value only", got)
+	}
+}
@@ -0,0 +1,84 @@
+package grpctrace
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/unit"
+	grpc_codes "google.golang.org/grpc/codes"
+	protov2 "google.golang.org/protobuf/proto"
+
+	"tracing/metrics"
+)
+
+// metricKeys are the span attributes RPC metrics share. Messages are
+// recorded as they cross, before the status code is known, so only the
+// duration adds rpc.grpc.status_code.
+var metricKeys = []attribute.Key{semconv.RPCSystemKey, semconv.RPCServiceKey, semconv.RPCMethodKey}
+
+// rpcMetrics are the histograms recording the RPCs of one side.
+type rpcMetrics struct {
+	duration     metrics.Histogram
+	requestSize  metrics.Histogram
+	responseSize metrics.Histogram
+}
+
+func newRPCMetrics(meter metrics.Meter, kind trace.SpanKind) rpcMetrics {
+	if kind == trace.SpanKindClient {
+		return rpcMetrics{
+			duration:     meter.Histogram(metrics.RPCClientDuration, "Duration of outbound RPCs", unit.Milliseconds),
+			requestSize:  meter.Histogram(metrics.RPCClientRequestSize, "Size of messages sent by outbound RPCs", unit.Bytes),
+			responseSize: meter.Histogram(metrics.RPCClientResponseSize, "Size of messages received by outbound RPCs", unit.Bytes),
+		}
+	}
+	return rpcMetrics{
+		duration:     meter.Histogram(metrics.RPCServerDuration, "Duration of inbound RPCs", unit.Milliseconds),
+		requestSize:  meter.Histogram(metrics.RPCServerRequestSize, "Size of messages received by inbound RPCs", unit.Bytes),
+		responseSize: meter.Histogram(metrics.RPCServerResponseSize, "Size of messages sent by inbound RPCs", unit.Bytes),
+	}
+}
+
+// metricAttrs returns the metric attributes of an RPC given the attributes
+// of its span.
+func metricAttrs(spanAttrs []attribute.KeyValue) []attribute.KeyValue {
+	return metrics.Select(spanAttrs, metricKeys...)
+}
+
+// finish records the duration d of an RPC that ended with code.
+func (m rpcMetrics) finish(ctx context.Context, attrs []attribute.KeyValue, d time.Duration, code grpc_codes.Code) {
+	attrs = append(attrs[:len(attrs):len(attrs)], grpcStatusCodeKey.Int64(int64(code)))
+	m.duration.RecordDuration(ctx, d, attrs...)
+}
+
+// request records the size of a request message, if it is a proto message.
+func (m rpcMetrics) request(ctx context.Context, attrs []attribute.KeyValue, msg interface{}) {
+	if size, ok := messageSize(msg); ok {
+		m.requestSize.Record(ctx, float64(size), attrs...)
+	}
+}
+
+// response records the size of a response message, if it is a proto
+// message.
+func (m rpcMetrics) response(ctx context.Context, attrs []attribute.KeyValue, msg interface{}) {
+	if size, ok := messageSize(msg); ok {
+		m.responseSize.Record(ctx, float64(size), attrs...)
+	}
+}
+
+// messageSize returns the encoded size of msg if it is a proto message.
+// Messages of the google.golang.org/protobuf API, e.g. those of the health
+// and reflection services, are sized by that package: gogo cannot walk
+// their oneofs.
+func messageSize(msg interface{}) (int, bool) {
+	switch p := msg.(type) {
+	case protov2.Message:
+		return protov2.Size(p), true
+	case proto.Message:
+		return proto.Size(p), true
+	}
+	return 0, false
+}
@@ -0,0 +1,170 @@
+package grpctrace
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"go.opentelemetry.io/otel/attribute"
+
+	"tracing/redact"
+)
+
+// PayloadCapture selects which RPC payloads are recorded as span attributes.
+type PayloadCapture int
+
+const (
+	// PayloadCaptureOff never records payloads.
+	PayloadCaptureOff PayloadCapture = iota
+	// PayloadCaptureRequest records the request message only.
+	PayloadCaptureRequest
+	// PayloadCaptureRequestResponse records the request and, when the RPC
+	// succeeded, the response message.
+	PayloadCaptureRequestResponse
+	// PayloadCaptureOnError records the request message only when the RPC
+	// failed.
+	PayloadCaptureOnError
+)
+
+const (
+	defaultPayloadLimit = 4096
+
+	// truncatedMarker is appended to payloads cut at the byte limit.
+	truncatedMarker = "...[truncated]"
+)
+
+var (
+	requestKey  = attribute.Key("rpc.grpc.request")
+	responseKey = attribute.Key("rpc.grpc.response")
+)
+
+type payloadConfig struct {
+	Capture PayloadCapture
+	Limit   int
+
+	Allow []string
+	Deny  []string
+
+	// Redactor is used when RedactorSet, even if nil; otherwise the global
+	// one is looked up for every payload.
+	Redactor    *redact.Redactor
+	RedactorSet bool
+
+	// Legacy also records payloads under the legacy "request" and
+	// "response" keys.
+	Legacy bool
+}
+
+// enabled reports whether payloads of fullMethod may be recorded. A method
+// matches an entry equal to it, or an entry ending in "/" that names its
+// service (e.g. "/tracing.HelloService/"). Deny entries win over allow
+// entries; an empty allow list allows every method.
+func (p payloadConfig) enabled(fullMethod string) bool {
+	if p.Capture == PayloadCaptureOff {
+		return false
+	}
+	if matchMethod(p.Deny, fullMethod) {
+		return false
+	}
+
+	return len(p.Allow) == 0 || matchMethod(p.Allow, fullMethod)
+}
+
+// attributes returns the payload attributes to record for a finished RPC.
+func (p payloadConfig) attributes(fullMethod string, req, resp interface{}, err error) []attribute.KeyValue {
+	if !p.enabled(fullMethod) {
+		return nil
+	}
+
+	var attrs []attribute.KeyValue
+	switch p.Capture {
+	case PayloadCaptureRequest:
+		attrs = p.appendPayload(attrs, requestKey, req)
+	case PayloadCaptureRequestResponse:
+		attrs = p.appendPayload(attrs, requestKey, req)
+		if err == nil {
+			attrs = p.appendPayload(attrs, responseKey, resp)
+		}
+	case PayloadCaptureOnError:
+		if err != nil {
+			attrs = p.appendPayload(attrs, requestKey, req)
+		}
+	}
+
+	return attrs
+}
+
+func (p payloadConfig) appendPayload(attrs []attribute.KeyValue, key attribute.Key, v interface{}) []attribute.KeyValue {
+	if v == nil {
+		return attrs
+	}
+
+	s, err := marshalPayload(v)
+	if err != nil {
+		return append(attrs, attribute.Key(string(key)+".error").String(err.Error()))
+	}
+
+	// Redact before truncating so a cut never exposes part of a secret.
+	s = p.redactor().JSON(s, v)
+
+	s = truncate(s, p.Limit)
+	attrs = append(attrs, key.String(s))
+	if p.Legacy {
+		attrs = append(attrs, legacyPayloadKey(key).String(s))
+	}
+	return attrs
+}
+
+// redactor returns the Redactor given with WithRedactor, or else the one
+// registered with redact.SetGlobal at the time of the call.
+func (p payloadConfig) redactor() *redact.Redactor {
+	if p.RedactorSet {
+		return p.Redactor
+	}
+	return redact.Global()
+}
+
+// marshalPayload renders gogo proto messages with jsonpb so field names and
+// well-known types follow the proto JSON mapping, and anything else with
+// encoding/json.
+func marshalPayload(v interface{}) (string, error) {
+	if m, ok := v.(proto.Message); ok {
+		return (&jsonpb.Marshaler{OrigName: true}).MarshalToString(m)
+	}
+
+	j, err := json.Marshal(v)
+	return string(j), err
+}
+
+// truncate cuts s to at most limit bytes, not counting the marker, without
+// splitting a UTF-8 sequence. A limit <= 0 disables truncation.
+func truncate(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + truncatedMarker
+}
+
+func matchMethod(patterns []string, fullMethod string) bool {
+	for _, p := range patterns {
+		if p == fullMethod || (strings.HasSuffix(p, "/") && strings.HasPrefix(fullMethod, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func legacyPayloadKey(key attribute.Key) attribute.Key {
+	if key == responseKey {
+		return legacyResponseKey
+	}
+	return legacyRequestKey
+}
@@ -0,0 +1,263 @@
+package grpctrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
+	tracing "tracing/proto"
+	"tracing/redact"
+	"tracing/spantest"
+)
+
+const pinMethod = "/tracing.HelloService/Pin"
+
+func TestPayloadCapture(t *testing.T) {
+	req := &tracing.Request{Id: "123Adam"}
+	resp := &tracing.Response{Id: "123Adam"}
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		opts []Option
+		err  error
+		want []attribute.KeyValue
+	}{
+		{
+			name: "off",
+			opts: []Option{WithPayloadCapture(PayloadCaptureOff)},
+		},
+		{
+			name: "request by default",
+			want: []attribute.KeyValue{requestKey.String(`{"id":"123Adam"}`)},
+		},
+		{
+			name: "request on error",
+			opts: []Option{WithPayloadCapture(PayloadCaptureRequest)},
+			err:  failed,
+			want: []attribute.KeyValue{requestKey.String(`{"id":"123Adam"}`)},
+		},
+		{
+			name: "request and response",
+			opts: []Option{WithPayloadCapture(PayloadCaptureRequestResponse)},
+			want: []attribute.KeyValue{
+				requestKey.String(`{"id":"123Adam"}`),
+				responseKey.String(`{"id":"123Adam"}`),
+			},
+		},
+		{
+			name: "request and response on error",
+			opts: []Option{WithPayloadCapture(PayloadCaptureRequestResponse)},
+			err:  failed,
+			want: []attribute.KeyValue{requestKey.String(`{"id":"123Adam"}`)},
+		},
+		{
+			name: "on error without error",
+			opts: []Option{WithPayloadCapture(PayloadCaptureOnError)},
+		},
+		{
+			name: "on error",
+			opts: []Option{WithPayloadCapture(PayloadCaptureOnError)},
+			err:  failed,
+			want: []attribute.KeyValue{requestKey.String(`{"id":"123Adam"}`)},
+		},
+		{
+			name: "size limit",
+			opts: []Option{WithPayloadCapture(PayloadCaptureRequestResponse), WithPayloadLimit(8)},
+			want: []attribute.KeyValue{
+				requestKey.String(`{"id":"1` + truncatedMarker),
+				responseKey.String(`{"id":"1` + truncatedMarker),
+			},
+		},
+		{
+			name: "no size limit",
+			opts: []Option{WithPayloadLimit(0)},
+			want: []attribute.KeyValue{requestKey.String(`{"id":"123Adam"}`)},
+		},
+		{
+			name: "legacy keys",
+			opts: []Option{WithLegacyAttributes()},
+			want: []attribute.KeyValue{
+				requestKey.String(`{"id":"123Adam"}`),
+				legacyRequestKey.String(`{"id":"123Adam"}`),
+			},
+		},
+		{
+			name: "redacted before truncation",
+			opts: []Option{WithPayloadLimit(12), WithRedactor(redact.New(redact.WithFields(redact.Mask, "id")))},
+			want: []attribute.KeyValue{requestKey.String(`{"id":"[REDA` + truncatedMarker)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithRedactor(nil)}, tt.opts...)
+			cfg := newConfig(opts...)
+
+			got := cfg.Payload.attributes(pinMethod, req, resp, tt.err)
+			if len(got) != len(tt.want) {
+				t.Fatalf("attributes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("attributes = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPayloadMarshalError(t *testing.T) {
+	cfg := newConfig(WithRedactor(nil))
+	got := cfg.Payload.attributes(pinMethod, make(chan int), nil, nil)
+	if len(got) != 1 || got[0].Key != "rpc.grpc.request.error" {
+		t.Errorf("attributes = %v, want rpc.grpc.request.error", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		limit int
+		want  string
+	}{
+		{"shorter", "abc", 4, "abc"},
+		{"at limit", "abcd", 4, "abcd"},
+		{"longer", "abcde", 4, "abcd" + truncatedMarker},
+		{"no limit", "abcde", 0, "abcde"},
+		{"negative limit", "abcde", -1, "abcde"},
+		// "é" is 2 bytes, "€" 3 and "😀" 4.
+		{"before 2-byte rune", "aébc", 1, "a" + truncatedMarker},
+		{"inside 2-byte rune", "aébc", 2, "a" + truncatedMarker},
+		{"after 2-byte rune", "aébc", 3, "aé" + truncatedMarker},
+		{"inside 3-byte rune", "a€bc", 3, "a" + truncatedMarker},
+		{"inside 4-byte rune", "a😀bc", 4, "a" + truncatedMarker},
+		{"after 4-byte rune", "a😀bc", 5, "a😀" + truncatedMarker},
+		{"inside first rune", "😀", 2, truncatedMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.s, tt.limit)
+			if got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncate(%q, %d) = %q, invalid UTF-8", tt.s, tt.limit, got)
+			}
+		})
+	}
+}
+
+func TestPayloadMethods(t *testing.T) {
+	const (
+		other   = "/tracing.HelloService/Other"
+		another = "/tracing.OtherService/Pin"
+	)
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]bool
+	}{
+		{
+			name: "all by default",
+			want: map[string]bool{pinMethod: true, other: true, another: true},
+		},
+		{
+			name: "allowed method",
+			opts: []Option{WithPayloadMethods(pinMethod)},
+			want: map[string]bool{pinMethod: true, other: false, another: false},
+		},
+		{
+			name: "allowed service",
+			opts: []Option{WithPayloadMethods("/tracing.HelloService/")},
+			want: map[string]bool{pinMethod: true, other: true, another: false},
+		},
+		{
+			name: "service without slash matches nothing",
+			opts: []Option{WithPayloadMethods("/tracing.HelloService")},
+			want: map[string]bool{pinMethod: false, other: false, another: false},
+		},
+		{
+			name: "denied method",
+			opts: []Option{WithoutPayloadMethods(pinMethod)},
+			want: map[string]bool{pinMethod: false, other: true, another: true},
+		},
+		{
+			name: "deny wins over allow",
+			opts: []Option{WithPayloadMethods("/tracing.HelloService/"), WithoutPayloadMethods(pinMethod)},
+			want: map[string]bool{pinMethod: false, other: true, another: false},
+		},
+		{
+			name: "off wins over allow",
+			opts: []Option{WithPayloadCapture(PayloadCaptureOff), WithPayloadMethods(pinMethod)},
+			want: map[string]bool{pinMethod: false, other: false, another: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(tt.opts...)
+			for method, want := range tt.want {
+				if got := cfg.Payload.enabled(method); got != want {
+					t.Errorf("enabled(%s) = %v, want %v", method, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPayloadRedactorIsReadLazily(t *testing.T) {
+	prev := redact.Global()
+	t.Cleanup(func() { redact.SetGlobal(prev) })
+
+	cfg := newConfig()
+	redact.SetGlobal(redact.New(redact.WithFields(redact.Mask, "id")))
+
+	attrs := cfg.Payload.attributes(pinMethod, &tracing.Request{Id: "123Adam"}, nil, nil)
+	if len(attrs) != 1 || attrs[0].Value.AsString() != `{"id":"[REDACTED]"}` {
+		t.Errorf("attributes = %v, want the request redacted by the global redactor set after newConfig", attrs)
+	}
+
+	// An explicit nil redactor is kept.
+	cfg = newConfig(WithRedactor(nil))
+	attrs = cfg.Payload.attributes(pinMethod, &tracing.Request{Id: "123Adam"}, nil, nil)
+	if len(attrs) != 1 || attrs[0].Value.AsString() != `{"id":"123Adam"}` {
+		t.Errorf("attributes = %v, want the request as is", attrs)
+	}
+}
+
+func TestPayloadSensitiveFields(t *testing.T) {
+	rec := spantest.New(t)
+	opts := []Option{
+		WithTracerProvider(rec.TracerProvider()),
+		WithRedactor(redact.New(redact.WithSensitiveOption(redact.Mask))),
+	}
+
+	var gotToken string
+	srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+		gotToken = in.GetToken()
+		return &tracing.Response{Id: in.GetId()}, nil
+	}}
+	cc := startServer(t, srv,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor(opts...))},
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+	)
+
+	req := &tracing.Request{Id: "123Adam", Token: "hunter2"}
+	if _, err := tracing.NewHelloServiceClient(cc).Pin(context.Background(), req); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	// Redaction only touches the span, never the message itself.
+	if gotToken != "hunter2" {
+		t.Errorf("handler got token %q, want hunter2", gotToken)
+	}
+	want := requestKey.String(`{"id":"123Adam","token":"[REDACTED]"}`)
+	rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindClient).HasAttribute(want)
+	rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindServer).HasAttribute(want)
+}
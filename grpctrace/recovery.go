@@ -0,0 +1,61 @@
+package grpctrace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpc_codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"tracing/internal/recovery"
+)
+
+// UnaryRecoveryInterceptor returns a grpc.UnaryServerInterceptor turning
+// panics of the handler into codes.Internal errors. The panic value and
+// stack trace are recorded as an exception event on the span of ctx, so
+// chain it after UnaryServerInterceptor:
+//
+//	grpc.ChainUnaryInterceptor(UnaryServerInterceptor(), UnaryRecoveryInterceptor())
+func UnaryRecoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = panicError(trace.SpanFromContext(ctx), r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoveryInterceptor returns a grpc.StreamServerInterceptor turning
+// panics of the handler into codes.Internal errors, like
+// UnaryRecoveryInterceptor. Chain it after StreamServerInterceptor.
+func StreamRecoveryInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = panicError(trace.SpanFromContext(ss.Context()), r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// panicError records the panic value r on span and returns the error
+// reported to the client.
+func panicError(span trace.Span, r interface{}) error {
+	return status.Errorf(grpc_codes.Internal, "panic: %s", recovery.RecordPanic(span, r))
+}
@@ -0,0 +1,61 @@
+package grpctrace
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpc_codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcStatusCodeKey is the attribute key of the RPC semantic conventions
+// missing from the semconv package of this OpenTelemetry version.
+var grpcStatusCodeKey = attribute.Key("rpc.grpc.status_code")
+
+// deadlineRemainingKey is not part of the semantic conventions. It records
+// the milliseconds left until the deadline of a client RPC when it started.
+var deadlineRemainingKey = attribute.Key("rpc.grpc.deadline_remaining_ms")
+
+// Attribute keys the interceptors used before they followed the semantic
+// conventions. WithLegacyAttributes records them next to the standard ones.
+var (
+	legacyStatusCodeKey = attribute.Key("statusCode")
+	legacyTraceIDKey    = attribute.Key("TraceID")
+	legacyRequestKey    = attribute.Key("request")
+	legacyResponseKey   = attribute.Key("response")
+)
+
+// statusCodeAttrs returns the attributes recording the gRPC status code c.
+func (cfg *config) statusCodeAttrs(c grpc_codes.Code) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{grpcStatusCodeKey.Int64(int64(c))}
+	if cfg.LegacyAttributes {
+		attrs = append(attrs, legacyStatusCodeKey.Int64(int64(c)))
+	}
+	return attrs
+}
+
+// traceIDAttrs returns the legacy attribute repeating the TraceID of span,
+// if legacy attributes are enabled.
+func (cfg *config) traceIDAttrs(span trace.Span) []attribute.KeyValue {
+	if !cfg.LegacyAttributes {
+		return nil
+	}
+	return []attribute.KeyValue{legacyTraceIDKey.String(span.SpanContext().TraceID().String())}
+}
+
+// setStatus records the gRPC status of err on span, a span of kind tracing
+// fullMethod, and marks the span as failed if the StatusClassifier for it
+// says so. Errors that are not gRPC status errors are converted like gRPC
+// converts context errors. The recorded code is returned.
+func (cfg *config) setStatus(span trace.Span, kind trace.SpanKind, fullMethod string, err error) grpc_codes.Code {
+	s, ok := status.FromError(err)
+	if !ok {
+		s = status.FromContextError(err)
+	}
+
+	span.SetAttributes(cfg.statusCodeAttrs(s.Code())...)
+	if err != nil && cfg.Status.classifier(kind, fullMethod)(s.Code()) {
+		span.SetStatus(codes.Error, s.Message())
+	}
+	return s.Code()
+}
@@ -0,0 +1,265 @@
+package grpctrace
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+// messageHeaderLen is the length of the prefix gRPC writes before every
+// message on the wire: a compression flag and the message length.
+const messageHeaderLen = 5
+
+var (
+	compressionKey = attribute.Key("rpc.grpc.compression")
+	wireSizeKey    = attribute.Key("rpc.grpc.wire_size")
+)
+
+// rpcState is what a stats handler keeps about a single RPC between its
+// events.
+type rpcState struct {
+	method string
+	span   trace.Span
+	// attrs are the metric attributes of the RPC.
+	attrs []attribute.KeyValue
+
+	receivedMessageID int64
+	sentMessageID     int64
+
+	mu  sync.Mutex
+	in  interface{}
+	out interface{}
+}
+
+type rpcStateKey struct{}
+
+// connState is what a stats handler keeps about a connection.
+type connState struct {
+	span trace.Span
+}
+
+type connStateKey struct{}
+
+// statsHandler is the stats.Handler behind NewServerHandler and
+// NewClientHandler.
+type statsHandler struct {
+	cfg  config
+	kind trace.SpanKind
+}
+
+// NewServerHandler returns a stats.Handler for use with grpc.StatsHandler.
+// It starts the same spans as UnaryServerInterceptor and
+// StreamServerInterceptor, and additionally records message sizes on the
+// wire, compression, header and trailer timing, and a span per connection
+// that RPC spans link to. Use it instead of the interceptors, not with
+// them.
+func NewServerHandler(opts ...Option) stats.Handler {
+	return &statsHandler{cfg: newConfig(opts...), kind: trace.SpanKindServer}
+}
+
+// NewClientHandler returns a stats.Handler for use with
+// grpc.WithStatsHandler. It starts the same spans as UnaryClientInterceptor
+// and StreamClientInterceptor, with the additions of NewServerHandler.
+func NewClientHandler(opts ...Option) stats.Handler {
+	return &statsHandler{cfg: newConfig(opts...), kind: trace.SpanKindClient}
+}
+
+// TagConn starts the span of a connection. It ends on stats.ConnEnd.
+func (h *statsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	var attrs []attribute.KeyValue
+	if info.RemoteAddr != nil {
+		attrs = peerAttr(info.RemoteAddr.String())
+	}
+
+	name := "grpc.client.connection"
+	if h.kind == trace.SpanKindServer {
+		name = "grpc.server.connection"
+	}
+	// A connection outlives the RPC that caused it to be dialed, so it gets
+	// a trace of its own.
+	_, span := h.cfg.Tracer.Start(
+		ctx,
+		name,
+		trace.WithNewRoot(),
+		trace.WithAttributes(append(attrs, semconv.RPCSystemGRPC)...),
+	)
+
+	return context.WithValue(ctx, connStateKey{}, &connState{span: span})
+}
+
+// HandleConn records the beginning and the end of a connection.
+func (h *statsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	state, ok := ctx.Value(connStateKey{}).(*connState)
+	if !ok {
+		return
+	}
+
+	switch s.(type) {
+	case *stats.ConnBegin:
+		state.span.AddEvent("connection begin")
+	case *stats.ConnEnd:
+		state.span.AddEvent("connection end")
+		state.span.End()
+	}
+}
+
+// TagRPC starts the span of an RPC, continuing the trace propagated by the
+// caller on the server and propagating it on the client.
+func (h *statsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	var (
+		name  string
+		attrs []attribute.KeyValue
+		span  trace.Span
+	)
+
+	if h.kind == trace.SpanKindServer {
+		requestMetadata, _ := metadata.FromIncomingContext(ctx)
+		if !h.cfg.traced(&InterceptorInfo{FullMethod: info.FullMethodName, Metadata: requestMetadata, Peer: peerFromCtx(ctx)}) {
+			return ctx
+		}
+		metadataCopy := requestMetadata.Copy()
+
+		entries, spanCtx := extract(ctx, &metadataCopy, h.cfg.Propagators)
+		ctx = baggage.ContextWithValues(ctx, h.cfg.Baggage.Filter(entries, peerFromCtx(ctx))...)
+
+		name, attrs = spanInfo(info.FullMethodName, peerFromCtx(ctx))
+		attrs = append(attrs, h.cfg.Baggage.Attributes(ctx)...)
+
+		startOpts := []trace.SpanOption{
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		}
+		if conn, ok := ctx.Value(connStateKey{}).(*connState); ok {
+			startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: conn.span.SpanContext()}))
+		}
+		ctx, span = h.cfg.Tracer.Start(trace.ContextWithRemoteSpanContext(ctx, spanCtx), name, startOpts...)
+	} else {
+		requestMetadata, _ := metadata.FromOutgoingContext(ctx)
+		if !h.cfg.traced(&InterceptorInfo{FullMethod: info.FullMethodName, Metadata: requestMetadata}) {
+			return ctx
+		}
+
+		name, attrs = spanInfo(info.FullMethodName, "")
+		attrs = append(attrs, deadlineAttr(ctx)...)
+		attrs = append(attrs, h.cfg.Baggage.Attributes(ctx)...)
+
+		ctx, span = h.cfg.Tracer.Start(
+			ctx,
+			name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		span.SetAttributes(h.cfg.traceIDAttrs(span)...)
+
+		metadataCopy := requestMetadata.Copy()
+		h.cfg.Propagators.Inject(ctx, &metadataSupplier{
+			metadata: &metadataCopy,
+		})
+		ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
+	}
+
+	return context.WithValue(ctx, rpcStateKey{}, &rpcState{method: info.FullMethodName, span: span, attrs: metricAttrs(attrs)})
+}
+
+// HandleRPC records the events of an RPC and ends its span on stats.End.
+func (h *statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	state, ok := ctx.Value(rpcStateKey{}).(*rpcState)
+	if !ok {
+		return
+	}
+	span := state.span
+	m := h.metrics()
+
+	switch s := s.(type) {
+	case *stats.InPayload:
+		id := atomic.AddInt64(&state.receivedMessageID, 1)
+		span.AddEvent("message",
+			trace.WithTimestamp(s.RecvTime),
+			trace.WithAttributes(messageAttrs(semconv.RPCMessageTypeReceived, id, s.Length, s.WireLength)...),
+		)
+		if h.kind == trace.SpanKindServer {
+			m.requestSize.Record(ctx, float64(s.Length), state.attrs...)
+		} else {
+			m.responseSize.Record(ctx, float64(s.Length), state.attrs...)
+		}
+		state.mu.Lock()
+		if state.in == nil {
+			state.in = s.Payload
+		}
+		state.mu.Unlock()
+	case *stats.OutPayload:
+		id := atomic.AddInt64(&state.sentMessageID, 1)
+		span.AddEvent("message",
+			trace.WithTimestamp(s.SentTime),
+			trace.WithAttributes(messageAttrs(semconv.RPCMessageTypeSent, id, s.Length, s.WireLength)...),
+		)
+		if h.kind == trace.SpanKindServer {
+			m.responseSize.Record(ctx, float64(s.Length), state.attrs...)
+		} else {
+			m.requestSize.Record(ctx, float64(s.Length), state.attrs...)
+		}
+		state.mu.Lock()
+		if state.out == nil {
+			state.out = s.Payload
+		}
+		state.mu.Unlock()
+	case *stats.InHeader:
+		span.AddEvent("header received", trace.WithAttributes(wireSizeKey.Int(s.WireLength)))
+		if s.Compression != "" {
+			span.SetAttributes(compressionKey.String(s.Compression))
+		}
+	case *stats.OutHeader:
+		if h.kind == trace.SpanKindClient && s.RemoteAddr != nil {
+			span.SetAttributes(peerAttr(s.RemoteAddr.String())...)
+		}
+		if s.Compression != "" {
+			span.SetAttributes(compressionKey.String(s.Compression))
+		}
+		span.AddEvent("header sent")
+	case *stats.InTrailer:
+		span.AddEvent("trailer received", trace.WithAttributes(wireSizeKey.Int(s.WireLength)))
+	case *stats.OutTrailer:
+		span.AddEvent("trailer sent")
+	case *stats.End:
+		state.mu.Lock()
+		req, resp := state.in, state.out
+		state.mu.Unlock()
+		if h.kind == trace.SpanKindClient {
+			req, resp = resp, req
+		}
+
+		span.SetAttributes(h.cfg.Payload.attributes(state.method, req, resp, s.Error)...)
+		code := h.cfg.setStatus(span, h.kind, state.method, s.Error)
+		m.finish(ctx, state.attrs, s.EndTime.Sub(s.BeginTime), code)
+		span.End(trace.WithTimestamp(s.EndTime))
+	}
+}
+
+// metrics returns the histograms of the side h instruments.
+func (h *statsHandler) metrics() rpcMetrics {
+	if h.kind == trace.SpanKindServer {
+		return h.cfg.ServerMetrics
+	}
+	return h.cfg.ClientMetrics
+}
+
+// messageAttrs describes a message of length bytes that took wireLength
+// bytes on the wire, prefix included.
+func messageAttrs(typ attribute.KeyValue, id int64, length, wireLength int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		typ,
+		semconv.RPCMessageIDKey.Int64(id),
+		semconv.RPCMessageUncompressedSizeKey.Int(length),
+	}
+	if wireLength >= messageHeaderLen {
+		attrs = append(attrs, semconv.RPCMessageCompressedSizeKey.Int(wireLength-messageHeaderLen))
+	}
+	return attrs
+}
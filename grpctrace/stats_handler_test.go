@@ -0,0 +1,123 @@
+package grpctrace
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+
+	tracing "tracing/proto"
+)
+
+// rpcSpans returns "kind name" for every RPC span, sorted.
+func rpcSpans(spans []*tracesdk.SpanSnapshot) []string {
+	var names []string
+	for _, span := range spans {
+		if span.SpanKind == trace.SpanKindServer || span.SpanKind == trace.SpanKindClient {
+			names = append(names, span.SpanKind.String()+" "+span.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestStatsHandlerMatchesInterceptors(t *testing.T) {
+	srv := &helloServer{pin: func(ctx context.Context, in *tracing.Request) (*tracing.Response, error) {
+		return &tracing.Response{Id: in.GetId()}, nil
+	}}
+	pin := func(cc *grpc.ClientConn) {
+		t.Helper()
+		_, err := tracing.NewHelloServiceClient(cc).Pin(context.Background(), &tracing.Request{Id: "123"}, grpc.UseCompressor(gzip.Name))
+		if err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+	}
+
+	interceptorExp := tracetest.NewInMemoryExporter()
+	opts := []Option{
+		WithTracerProvider(tracesdk.NewTracerProvider(tracesdk.WithSyncer(interceptorExp))),
+		WithPropagators(propagation.TraceContext{}),
+	}
+	pin(startServer(t, srv,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor(opts...))},
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+	))
+
+	handlerExp := tracetest.NewInMemoryExporter()
+	opts = []Option{
+		WithTracerProvider(tracesdk.NewTracerProvider(tracesdk.WithSyncer(handlerExp))),
+		WithPropagators(propagation.TraceContext{}),
+	}
+	pin(startServer(t, srv,
+		[]grpc.ServerOption{grpc.StatsHandler(NewServerHandler(opts...))},
+		grpc.WithStatsHandler(NewClientHandler(opts...)),
+	))
+
+	want := rpcSpans(interceptorExp.GetSpans())
+	got := rpcSpans(handlerExp.GetSpans())
+	if len(got) != 2 || len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("stats handler spans = %v, interceptor spans = %v", got, want)
+	}
+
+	var client, server *tracesdk.SpanSnapshot
+	for _, span := range handlerExp.GetSpans() {
+		switch span.SpanKind {
+		case trace.SpanKindClient:
+			client = span
+		case trace.SpanKindServer:
+			server = span
+		}
+	}
+
+	if server.Parent.SpanID() != client.SpanContext.SpanID() {
+		t.Errorf("server span parent = %s, want client span %s", server.Parent.SpanID(), client.SpanContext.SpanID())
+	}
+	if len(server.Links) != 1 {
+		t.Errorf("server span has %d links, want one to its connection", len(server.Links))
+	}
+
+	for _, span := range []*tracesdk.SpanSnapshot{client, server} {
+		if !hasAttr(span.Attributes, compressionKey.String(gzip.Name)) {
+			t.Errorf("%s span attributes %v lack %s=gzip", span.SpanKind, span.Attributes, compressionKey)
+		}
+		var messages int
+		for _, e := range span.MessageEvents {
+			if e.Name != "message" {
+				continue
+			}
+			messages++
+			if !hasKey(e.Attributes, semconv.RPCMessageCompressedSizeKey) || !hasKey(e.Attributes, semconv.RPCMessageUncompressedSizeKey) {
+				t.Errorf("%s span message event %v lacks sizes", span.SpanKind, e.Attributes)
+			}
+		}
+		if messages != 2 {
+			t.Errorf("%s span has %d message events, want 2", span.SpanKind, messages)
+		}
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if kv.Key == want.Key && kv.Value.Emit() == want.Value.Emit() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasKey(attrs []attribute.KeyValue, key attribute.Key) bool {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package grpctrace
+
+import (
+	"go.opentelemetry.io/otel/trace"
+	grpc_codes "google.golang.org/grpc/codes"
+)
+
+// StatusClassifier reports whether an RPC that ended with code c failed,
+// i.e. whether its span status is set to codes.Error. The span always
+// records the code itself in rpc.grpc.status_code.
+type StatusClassifier func(c grpc_codes.Code) bool
+
+// ErrorCodes returns a StatusClassifier treating exactly codes as failures.
+func ErrorCodes(codes ...grpc_codes.Code) StatusClassifier {
+	set := make(map[grpc_codes.Code]bool, len(codes))
+	for _, c := range codes {
+		set[c] = true
+	}
+	return func(c grpc_codes.Code) bool {
+		return set[c]
+	}
+}
+
+var (
+	// ClientStatusClassifier treats every code but OK as a failure, as the
+	// OpenTelemetry gRPC conventions require for client spans.
+	ClientStatusClassifier StatusClassifier = func(c grpc_codes.Code) bool {
+		return c != grpc_codes.OK
+	}
+
+	// ServerStatusClassifier only treats the codes the OpenTelemetry gRPC
+	// conventions attribute to the server as failures. Codes such as
+	// NotFound or InvalidArgument report mistakes of the client.
+	ServerStatusClassifier = ErrorCodes(
+		grpc_codes.Unknown,
+		grpc_codes.DeadlineExceeded,
+		grpc_codes.Unimplemented,
+		grpc_codes.Internal,
+		grpc_codes.Unavailable,
+		grpc_codes.DataLoss,
+	)
+)
+
+type methodClassifier struct {
+	method   string
+	kind     trace.SpanKind
+	classify StatusClassifier
+}
+
+// statusClassifiers picks the StatusClassifier of a span.
+type statusClassifiers struct {
+	byKind   map[trace.SpanKind]StatusClassifier
+	byMethod []methodClassifier
+}
+
+// classifier returns the classifier for spans of kind tracing fullMethod:
+// the last method override matching it, else the one for kind, else the
+// default for kind.
+func (s statusClassifiers) classifier(kind trace.SpanKind, fullMethod string) StatusClassifier {
+	for i := len(s.byMethod) - 1; i >= 0; i-- {
+		m := s.byMethod[i]
+		if m.kind == kind && matchMethod([]string{m.method}, fullMethod) {
+			return m.classify
+		}
+	}
+	if c, ok := s.byKind[kind]; ok {
+		return c
+	}
+	if kind == trace.SpanKindServer {
+		return ServerStatusClassifier
+	}
+	return ClientStatusClassifier
+}
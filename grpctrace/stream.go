@@ -0,0 +1,291 @@
+package grpctrace
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type messageType attribute.KeyValue
+
+// Event adds an event of the messageType to the span associated with the
+// passed context with id and size (if message is a proto message).
+func (m messageType) Event(ctx context.Context, id int, message interface{}) {
+	span := trace.SpanFromContext(ctx)
+	m.event(span, id, message)
+}
+
+func (m messageType) event(span trace.Span, id int, message interface{}) {
+	attrs := []attribute.KeyValue{
+		attribute.KeyValue(m),
+		semconv.RPCMessageIDKey.Int(id),
+	}
+	if size, ok := messageSize(message); ok {
+		attrs = append(attrs, semconv.RPCMessageUncompressedSizeKey.Int(size))
+	}
+	span.AddEvent("message", trace.WithAttributes(attrs...))
+}
+
+var (
+	messageSent     = messageType(semconv.RPCMessageTypeSent)
+	messageReceived = messageType(semconv.RPCMessageTypeReceived)
+)
+
+// serverStream wraps grpc.ServerStream so the handler sees the traced
+// context and every message crossing the stream is recorded on the span
+// and in the message size metrics.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	metrics rpcMetrics
+	attrs   []attribute.KeyValue
+
+	receivedMessageID int
+	sentMessageID     int
+}
+
+func (w *serverStream) Context() context.Context {
+	return w.ctx
+}
+
+func (w *serverStream) RecvMsg(m interface{}) error {
+	err := w.ServerStream.RecvMsg(m)
+
+	if err == nil {
+		w.receivedMessageID++
+		messageReceived.Event(w.Context(), w.receivedMessageID, m)
+		w.metrics.request(w.ctx, w.attrs, m)
+	}
+
+	return err
+}
+
+func (w *serverStream) SendMsg(m interface{}) error {
+	err := w.ServerStream.SendMsg(m)
+
+	if err == nil {
+		w.sentMessageID++
+		messageSent.Event(w.Context(), w.sentMessageID, m)
+		w.metrics.response(w.ctx, w.attrs, m)
+	}
+
+	return err
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor suitable
+// for use in a grpc.NewServer call. The span stays open until the handler
+// returns.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(opts...)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+
+		requestMetadata, _ := metadata.FromIncomingContext(ctx)
+		if !cfg.traced(&InterceptorInfo{FullMethod: info.FullMethod, Metadata: requestMetadata, Peer: peerFromCtx(ctx)}) {
+			return handler(srv, ss)
+		}
+		metadataCopy := requestMetadata.Copy()
+
+		entries, spanCtx := extract(ctx, &metadataCopy, cfg.Propagators)
+		ctx = baggage.ContextWithValues(ctx, cfg.Baggage.Filter(entries, peerFromCtx(ctx))...)
+
+		name, attr := spanInfo(info.FullMethod, peerFromCtx(ctx))
+		attr = append(attr, cfg.Baggage.Attributes(ctx)...)
+
+		ctx, span := cfg.Tracer.Start(
+			trace.ContextWithRemoteSpanContext(ctx, spanCtx),
+			name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attr...),
+		)
+		defer span.End()
+
+		metricAttr := metricAttrs(attr)
+		start := time.Now()
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx, metrics: cfg.ServerMetrics, attrs: metricAttr})
+		code := cfg.setStatus(span, trace.SpanKindServer, info.FullMethod, err)
+		cfg.ServerMetrics.finish(ctx, metricAttr, time.Since(start), code)
+
+		return err
+	}
+}
+
+// clientStream wraps grpc.ClientStream and ends the span once the stream
+// is finished: on io.EOF, on the first error, after the single response of
+// a non server-streaming RPC, or when the caller's context is done.
+type clientStream struct {
+	grpc.ClientStream
+
+	desc   *grpc.StreamDesc
+	method string
+	span   trace.Span
+	cfg    *config
+
+	// ctx is the context of span, recorded with the metrics.
+	ctx   context.Context
+	attrs []attribute.KeyValue
+	start time.Time
+
+	finishOnce sync.Once
+	done       chan struct{}
+
+	receivedMessageID int
+	sentMessageID     int
+}
+
+func newClientStream(ctx context.Context, s grpc.ClientStream, desc *grpc.StreamDesc, method string, span trace.Span, attrs []attribute.KeyValue, start time.Time, cfg *config) *clientStream {
+	w := &clientStream{
+		ClientStream: s,
+		desc:         desc,
+		method:       method,
+		span:         span,
+		cfg:          cfg,
+		ctx:          ctx,
+		attrs:        attrs,
+		start:        start,
+		done:         make(chan struct{}),
+	}
+
+	// A context that is never done, e.g. context.Background(), cannot end
+	// the span; waiting on it would leak the goroutine of every stream.
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				w.finish(ctx.Err())
+			case <-w.done:
+			}
+		}()
+	}
+
+	return w
+}
+
+func (w *clientStream) RecvMsg(m interface{}) error {
+	err := w.ClientStream.RecvMsg(m)
+
+	switch {
+	case err == nil && !w.desc.ServerStreams:
+		w.receivedMessageID++
+		messageReceived.event(w.span, w.receivedMessageID, m)
+		w.cfg.ClientMetrics.response(w.ctx, w.attrs, m)
+		w.finish(nil)
+	case err == nil:
+		w.receivedMessageID++
+		messageReceived.event(w.span, w.receivedMessageID, m)
+		w.cfg.ClientMetrics.response(w.ctx, w.attrs, m)
+	case err == io.EOF:
+		w.finish(nil)
+	default:
+		w.finish(err)
+	}
+
+	return err
+}
+
+func (w *clientStream) SendMsg(m interface{}) error {
+	err := w.ClientStream.SendMsg(m)
+
+	if err == nil {
+		w.sentMessageID++
+		messageSent.event(w.span, w.sentMessageID, m)
+		w.cfg.ClientMetrics.request(w.ctx, w.attrs, m)
+	}
+
+	// io.EOF means the stream was aborted; the real status is reported by RecvMsg.
+	if err != nil && err != io.EOF {
+		w.finish(err)
+	}
+
+	return err
+}
+
+func (w *clientStream) Header() (metadata.MD, error) {
+	md, err := w.ClientStream.Header()
+
+	if err != nil {
+		w.finish(err)
+	}
+
+	return md, err
+}
+
+func (w *clientStream) CloseSend() error {
+	err := w.ClientStream.CloseSend()
+
+	if err != nil {
+		w.finish(err)
+	}
+
+	return err
+}
+
+func (w *clientStream) finish(err error) {
+	w.finishOnce.Do(func() {
+		close(w.done)
+
+		code := w.cfg.setStatus(w.span, trace.SpanKindClient, w.method, err)
+		w.cfg.ClientMetrics.finish(w.ctx, w.attrs, time.Since(w.start), code)
+		w.span.End()
+	})
+}
+
+// StreamClientInterceptor returns a grpc.StreamClientInterceptor suitable
+// for use in a grpc.Dial call. The span stays open for the lifetime of the
+// stream, so callers must finish every stream the way grpc.ClientConn.NewStream
+// requires anyway: cancel its context, or call RecvMsg until it returns an
+// error, io.EOF included. A stream dropped under a context that is never
+// done leaves its span unended.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	cfg := newConfig(opts...)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		requestMetadata, _ := metadata.FromOutgoingContext(ctx)
+		if !cfg.traced(&InterceptorInfo{FullMethod: method, Metadata: requestMetadata, Peer: cc.Target()}) {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+		metadataCopy := requestMetadata.Copy()
+
+		name, attr := spanInfo(method, cc.Target())
+		attr = append(attr, deadlineAttr(ctx)...)
+		attr = append(attr, cfg.Baggage.Attributes(ctx)...)
+
+		var span trace.Span
+		ctx, span = cfg.Tracer.Start(
+			ctx,
+			name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attr...),
+		)
+
+		cfg.Propagators.Inject(ctx, &metadataSupplier{
+			metadata: &metadataCopy,
+		})
+		ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
+
+		metricAttr := metricAttrs(attr)
+		start := time.Now()
+
+		s, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			code := cfg.setStatus(span, trace.SpanKindClient, method, err)
+			cfg.ClientMetrics.finish(ctx, metricAttr, time.Since(start), code)
+			span.End()
+
+			return s, err
+		}
+
+		return newClientStream(ctx, s, desc, method, span, metricAttr, start, &cfg), nil
+	}
+}
@@ -0,0 +1,208 @@
+package grpctrace
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpc_codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	tracing "tracing/proto"
+	"tracing/spantest"
+)
+
+// echoMethod is the bidirectional streaming method of echoService.
+const echoMethod = "/test.Echo/Echo"
+
+var echoStreamDesc = grpc.StreamDesc{
+	StreamName:    "Echo",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// echoService registers handler as the Echo method of a test.Echo service,
+// as protoc-gen-go-grpc would for a streaming method.
+func echoService(handler func(grpc.ServerStream) error) *grpc.ServiceDesc {
+	desc := echoStreamDesc
+	desc.Handler = func(_ interface{}, stream grpc.ServerStream) error {
+		return handler(stream)
+	}
+	return &grpc.ServiceDesc{
+		ServiceName: "test.Echo",
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{desc},
+	}
+}
+
+// echo sends back every request until the client closes the stream.
+func echo(stream grpc.ServerStream) error {
+	for {
+		var req tracing.Request
+		if err := stream.RecvMsg(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(&tracing.Response{Id: req.GetId()}); err != nil {
+			return err
+		}
+	}
+}
+
+// startEchoServer serves handler with the stream interceptors recording to
+// rec and returns a client connection to it.
+func startEchoServer(t *testing.T, rec *spantest.Recorder, handler func(grpc.ServerStream) error) *grpc.ClientConn {
+	t.Helper()
+	opts := []Option{WithTracerProvider(rec.TracerProvider()), WithPropagators(propagation.TraceContext{})}
+
+	s := grpc.NewServer(grpc.StreamInterceptor(StreamServerInterceptor(opts...)))
+	s.RegisterService(echoService(handler), nil)
+	return dialServer(t, s, grpc.WithStreamInterceptor(StreamClientInterceptor(opts...)))
+}
+
+// waitForSpans waits for n spans to end; the server span ends after the
+// client has seen the status.
+func waitForSpans(t *testing.T, rec *spantest.Recorder, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.Spans()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	rec.Len(n)
+}
+
+func TestStreamInterceptorsEOF(t *testing.T) {
+	rec := spantest.New(t)
+	cc := startEchoServer(t, rec, echo)
+
+	stream, err := cc.NewStream(context.Background(), &echoStreamDesc, echoMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := stream.SendMsg(&tracing.Request{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+		var resp tracing.Response
+		if err := stream.RecvMsg(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&tracing.Response{}); err != io.EOF {
+		t.Fatalf("RecvMsg after CloseSend = %v, want io.EOF", err)
+	}
+
+	waitForSpans(t, rec, 2)
+	client := rec.SpanOfKind("test.Echo/Echo", trace.SpanKindClient).
+		IsRoot().
+		HasAttribute(grpcStatusCodeKey.Int64(int64(grpc_codes.OK))).
+		HasStatus(codes.Unset).
+		HasEvent("message", semconv.RPCMessageTypeSent, semconv.RPCMessageIDKey.Int(2)).
+		HasEvent("message", semconv.RPCMessageTypeReceived, semconv.RPCMessageIDKey.Int(2))
+	rec.SpanOfKind("test.Echo/Echo", trace.SpanKindServer).
+		HasParent(client).
+		HasAttribute(grpcStatusCodeKey.Int64(int64(grpc_codes.OK))).
+		HasStatus(codes.Unset).
+		HasEvent("message", semconv.RPCMessageTypeReceived, semconv.RPCMessageIDKey.Int(2)).
+		HasEvent("message", semconv.RPCMessageTypeSent, semconv.RPCMessageIDKey.Int(2))
+
+	for _, kind := range []trace.SpanKind{trace.SpanKindClient, trace.SpanKindServer} {
+		if n := len(rec.SpanOfKind("test.Echo/Echo", kind).Snapshot().MessageEvents); n != 4 {
+			t.Errorf("%s span has %d message events, want 4", kind, n)
+		}
+	}
+}
+
+func TestStreamInterceptorsHandlerError(t *testing.T) {
+	rec := spantest.New(t)
+	cc := startEchoServer(t, rec, func(stream grpc.ServerStream) error {
+		if err := stream.RecvMsg(&tracing.Request{}); err != nil {
+			return err
+		}
+		return status.Error(grpc_codes.Internal, "echo broke")
+	})
+
+	stream, err := cc.NewStream(context.Background(), &echoStreamDesc, echoMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&tracing.Request{Id: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&tracing.Response{}); status.Code(err) != grpc_codes.Internal {
+		t.Fatalf("RecvMsg = %v, want Internal", err)
+	}
+
+	waitForSpans(t, rec, 2)
+	rec.SpanOfKind("test.Echo/Echo", trace.SpanKindClient).
+		HasAttribute(grpcStatusCodeKey.Int64(int64(grpc_codes.Internal))).
+		HasStatus(codes.Error).
+		HasStatusMessage("echo broke").
+		HasEvent("message", semconv.RPCMessageTypeSent, semconv.RPCMessageIDKey.Int(1))
+	rec.SpanOfKind("test.Echo/Echo", trace.SpanKindServer).
+		HasAttribute(grpcStatusCodeKey.Int64(int64(grpc_codes.Internal))).
+		HasStatus(codes.Error).
+		HasEvent("message", semconv.RPCMessageTypeReceived, semconv.RPCMessageIDKey.Int(1))
+
+	for _, kind := range []trace.SpanKind{trace.SpanKindClient, trace.SpanKindServer} {
+		if n := len(rec.SpanOfKind("test.Echo/Echo", kind).Snapshot().MessageEvents); n != 1 {
+			t.Errorf("%s span has %d message events, want 1", kind, n)
+		}
+	}
+}
+
+func TestStreamInterceptorsCancel(t *testing.T) {
+	rec := spantest.New(t)
+	received := make(chan struct{})
+	cc := startEchoServer(t, rec, func(stream grpc.ServerStream) error {
+		if err := stream.RecvMsg(&tracing.Request{}); err != nil {
+			return err
+		}
+		close(received)
+		<-stream.Context().Done()
+		// Messages that fail to send are not recorded.
+		if err := stream.SendMsg(&tracing.Response{Id: "1"}); err == nil {
+			t.Error("SendMsg on a canceled stream succeeded")
+		}
+		return stream.Context().Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cc.NewStream(ctx, &echoStreamDesc, echoMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&tracing.Request{Id: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	<-received
+
+	// The client span ends on cancellation, without another call on the
+	// stream.
+	cancel()
+	waitForSpans(t, rec, 2)
+	rec.SpanOfKind("test.Echo/Echo", trace.SpanKindClient).
+		HasAttribute(grpcStatusCodeKey.Int64(int64(grpc_codes.Canceled))).
+		HasStatus(codes.Error).
+		HasEvent("message", semconv.RPCMessageTypeSent, semconv.RPCMessageIDKey.Int(1))
+	rec.SpanOfKind("test.Echo/Echo", trace.SpanKindServer).
+		HasAttribute(grpcStatusCodeKey.Int64(int64(grpc_codes.Canceled))).
+		HasStatus(codes.Unset).
+		HasEvent("message", semconv.RPCMessageTypeReceived, semconv.RPCMessageIDKey.Int(1))
+
+	for _, kind := range []trace.SpanKind{trace.SpanKindClient, trace.SpanKindServer} {
+		if n := len(rec.SpanOfKind("test.Echo/Echo", kind).Snapshot().MessageEvents); n != 1 {
+			t.Errorf("%s span has %d message events, want 1", kind, n)
+		}
+	}
+}
@@ -8,44 +8,44 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptrace"
-	"time"
+	"os"
+	"tracing/tracer"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/semconv"
 	"go.opentelemetry.io/otel/trace"
-
-	"go.opentelemetry.io/otel/exporters/stdout"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 )
 
-func initTracer() {
-	// Create stdout exporter to be able to retrieve
+func initTracer() tracer.ShutdownFunc {
+	// Use the stdout exporter to be able to retrieve
 	// the collected spans.
-	exporter, err := stdout.NewExporter(stdout.WithPrettyPrint())
+	cfg := tracer.DefaultConfig()
+	cfg.ServiceName = "ExampleClient"
+	cfg.Exporter = tracer.ExporterStdout
+
+	// For the demonstration, use the always_on sampler to sample all traces.
+	// In a production application, set a ratio based sampler, rate limit or
+	// sampling rules in the file named by TRACER_CONFIG or the environment.
+	cfg.Sampler = tracer.SamplerAlwaysOn
+
+	cfg, err := cfg.Load(os.Getenv(tracer.ConfigFileEnv))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// For the demonstration, use sdktrace.AlwaysSample sampler to sample all traces.
-	// In a production application, use sdktrace.ProbabilitySampler with a desired probability.
-	tp := tracesdk.NewTracerProvider(
-		tracesdk.WithSampler(tracesdk.AlwaysSample()),
-		tracesdk.WithSyncer(exporter),
-	)
+	_, shutdown, err := tracer.New(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return shutdown
 }
 
 func main() {
-	initTracer()
+	shutdown := initTracer()
 	url := flag.String("server", "http://localhost:7777/hello", "server url")
 	flag.Parse()
 
@@ -81,7 +81,10 @@ func main() {
 	}
 
 	fmt.Printf("Response Received: %s\n\n\n", body)
-	fmt.Printf("Waiting for few seconds to export spans ...\n\n")
-	time.Sleep(10 * time.Second)
-	fmt.Printf("Inspect traces on stdout\n")
+
+	stats, err := shutdown(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Exported %d spans, dropped %d. Inspect traces on stdout\n", stats.Exported, stats.Dropped)
 }
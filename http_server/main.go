@@ -1,55 +1,60 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"time"
+	"tracing/baggagepolicy"
+	"tracing/tracer"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/exporters/stdout"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	"go.opentelemetry.io/otel/semconv"
 	"go.opentelemetry.io/otel/trace"
 )
 
-func initTracer() {
-	// Create stdout exporter to be able to retrieve
+func initTracer() (tracer.Config, tracer.ShutdownFunc) {
+	// Use the stdout exporter to be able to retrieve
 	// the collected spans.
-	exporter, err := stdout.NewExporter(stdout.WithPrettyPrint())
+	cfg := tracer.DefaultConfig()
+	cfg.ServiceName = "ExampleService"
+	cfg.Exporter = tracer.ExporterStdout
+
+	// For the demonstration, use the always_on sampler to sample all traces.
+	// In a production application, set a ratio based sampler, rate limit or
+	// sampling rules in the file named by TRACER_CONFIG or the environment.
+	cfg.Sampler = tracer.SamplerAlwaysOn
+
+	cfg, err := cfg.Load(os.Getenv(tracer.ConfigFileEnv))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// For the demonstration, use sdktrace.AlwaysSample sampler to sample all traces.
-	// In a production application, use sdktrace.ProbabilitySampler with a desired probability.
-	tp := tracesdk.NewTracerProvider(
-		tracesdk.WithSampler(tracesdk.AlwaysSample()),
-		tracesdk.WithSyncer(exporter),
-		tracesdk.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String("ExampleService"))),
-	)
+	_, shutdown, err := tracer.New(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return cfg, shutdown
 }
 
 func main() {
-	initTracer()
+	cfg, shutdown := initTracer()
 
-	uk := attribute.Key("username")
+	// Only the username is accepted from callers, and it is recorded on the
+	// span of the request.
+	policy := baggagepolicy.New(
+		baggagepolicy.WithAllowedKeys("username"),
+		baggagepolicy.WithMaxBytes(256),
+		baggagepolicy.WithPromotedKeys("username"),
+	)
 
 	helloHandler := func(w http.ResponseWriter, req *http.Request) {
-		ctx := req.Context()
+		ctx := policy.Apply(req.Context(), req.RemoteAddr)
 		span := trace.SpanFromContext(ctx)
-		username := baggage.Value(ctx, uk)
-		span.AddEvent("handling this...", trace.WithAttributes(uk.String(username.AsString())))
+		span.SetAttributes(policy.Attributes(ctx)...)
+		span.AddEvent("handling this...")
 
 		_, _ = io.WriteString(w, "Hello, world!\n")
 	}
@@ -57,8 +62,19 @@ func main() {
 	otelHandler := otelhttp.NewHandler(http.HandlerFunc(helloHandler), "Hello")
 
 	http.Handle("/hello", otelHandler)
-	err := http.ListenAndServe(":7777", nil)
+
+	srv := &http.Server{Addr: ":7777"}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("failed to serve: %v", err)
+		}
+		cancel()
+	}()
+
+	stats, err := tracer.Graceful(ctx, time.Duration(cfg.ShutdownTimeout), shutdown, srv.Shutdown)
 	if err != nil {
-		panic(err)
+		log.Printf("shutdown: %v", err)
 	}
+	log.Printf("exported %d spans, dropped %d", stats.Exported, stats.Dropped)
 }
@@ -0,0 +1,27 @@
+// Package recovery records recovered panics on spans for the grpctrace and
+// iristrace recovery handlers.
+package recovery
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RecordPanic adds an exception event for the panic value r to span, marks
+// it as failed and returns the panic message.
+func RecordPanic(span trace.Span, r interface{}) string {
+	msg := fmt.Sprint(r)
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+		semconv.ExceptionTypeKey.String(fmt.Sprintf("%T", r)),
+		semconv.ExceptionMessageKey.String(msg),
+		semconv.ExceptionStacktraceKey.String(string(debug.Stack())),
+		semconv.ExceptionEscapedKey.Bool(true),
+	))
+	span.SetStatus(codes.Error, "panic: "+msg)
+
+	return msg
+}
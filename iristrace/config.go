@@ -0,0 +1,138 @@
+package iristrace
+
+import (
+	"go.opentelemetry.io/contrib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/unit"
+
+	"tracing/baggagepolicy"
+	"tracing/metrics"
+	"tracing/redact"
+)
+
+const (
+	// instrumentationName is the name of the tracer and meter the
+	// middleware creates its spans and metrics with.
+	instrumentationName = "tracing/iristrace"
+)
+
+type config struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Propagators    propagation.TextMapPropagator
+	Baggage        *baggagepolicy.Policy
+	ServerName     string
+	Filters        []Filter
+	RequestHeaders []string
+
+	// Redactor is used when RedactorSet, even if nil; otherwise the global
+	// one is looked up for every request.
+	Redactor    *redact.Redactor
+	RedactorSet bool
+
+	Tracer       trace.Tracer
+	Duration     metrics.Histogram
+	RequestSize  metrics.Histogram
+	ResponseSize metrics.Histogram
+}
+
+func newConfig(opts ...Option) config {
+	cfg := config{
+		Propagators:    otel.GetTextMapPropagator(),
+		TracerProvider: otel.GetTracerProvider(),
+		MeterProvider:  global.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	cfg.Tracer = cfg.TracerProvider.Tracer(
+		instrumentationName,
+		trace.WithInstrumentationVersion(contrib.SemVersion()),
+	)
+	meter := metrics.NewMeter(cfg.MeterProvider, instrumentationName)
+	cfg.Duration = meter.Histogram(metrics.HTTPServerDuration, "Duration of inbound HTTP requests", unit.Milliseconds)
+	cfg.RequestSize = meter.Histogram(metrics.HTTPServerRequestSize, "Size of inbound HTTP request bodies", unit.Bytes)
+	cfg.ResponseSize = meter.Histogram(metrics.HTTPServerResponseSize, "Size of inbound HTTP response bodies", unit.Bytes)
+
+	return cfg
+}
+
+// Option specifies instrumentation configuration options.
+type Option func(*config)
+
+// WithTracerProvider specifies a tracer provider to use for creating a tracer.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.TracerProvider = provider
+	}
+}
+
+// WithMeterProvider specifies a meter provider to use for recording the
+// http.server.* metrics of traced requests. If none is specified, the
+// global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(cfg *config) {
+		cfg.MeterProvider = provider
+	}
+}
+
+// WithPropagators specifies propagators to use for extracting
+// information from the HTTP requests. If none are specified, global
+// ones will be used.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return func(cfg *config) {
+		cfg.Propagators = propagators
+	}
+}
+
+// WithBaggagePolicy specifies the policy filtering the baggage accepted
+// from callers and copying baggage members onto server spans. By default
+// all baggage is accepted and none is recorded on spans.
+func WithBaggagePolicy(p *baggagepolicy.Policy) Option {
+	return func(cfg *config) {
+		cfg.Baggage = p
+	}
+}
+
+// WithServerName specifies the http.server_name attribute of server spans,
+// the name of the virtual host. None is recorded by default.
+func WithServerName(name string) Option {
+	return func(cfg *config) {
+		cfg.ServerName = name
+	}
+}
+
+// WithFilter specifies filters deciding which requests are traced; a
+// request is traced if every filter returns true. By default every request
+// is traced.
+func WithFilter(filters ...Filter) Option {
+	return func(cfg *config) {
+		cfg.Filters = append(cfg.Filters, filters...)
+	}
+}
+
+// WithRequestHeaders records the request headers names as
+// http.request.header.<name> attributes of server spans, lowercase with "-"
+// replaced by "_". Multiple values are joined with ",". Values are redacted
+// with the redactor of WithRedactor.
+func WithRequestHeaders(names ...string) Option {
+	return func(cfg *config) {
+		cfg.RequestHeaders = append(cfg.RequestHeaders, names...)
+	}
+}
+
+// WithRedactor specifies the redactor applied to recorded request headers.
+// If none is specified, the global one registered with redact.SetGlobal
+// when the request is served is used.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(cfg *config) {
+		cfg.Redactor = r
+		cfg.RedactorSet = true
+	}
+}
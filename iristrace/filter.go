@@ -0,0 +1,40 @@
+package iristrace
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Filter reports whether the request r is traced.
+type Filter func(r *http.Request) bool
+
+// SkipHealthChecks leaves requests for /health, /healthz and any other
+// path starting with /health untraced.
+var SkipHealthChecks = SkipPaths("/health*")
+
+// SkipPaths returns a Filter leaving requests for the given paths
+// untraced. A path ending in "*" matches every path it prefixes.
+func SkipPaths(paths ...string) Filter {
+	return func(r *http.Request) bool {
+		for _, p := range paths {
+			if prefix := strings.TrimSuffix(p, "*"); prefix != p {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					return false
+				}
+			} else if p == r.URL.Path {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// traced reports whether every filter of cfg lets r through.
+func (cfg *config) traced(r *http.Request) bool {
+	for _, f := range cfg.Filters {
+		if !f(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+// Package iristrace traces requests served by iris applications.
+package iristrace
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"tracing/metrics"
+	"tracing/redact"
+)
+
+// failedKey marks requests whose span status was already set to an error
+// with a more precise description than the status code gives.
+const failedKey = "iristrace.failed"
+
+// tracedKey marks requests the middleware already started a span for, so
+// the error code handlers iris runs after the route do not start another.
+const tracedKey = "iristrace.traced"
+
+// metricKeys are the span attributes the metrics of a request share.
+var metricKeys = []attribute.Key{semconv.HTTPMethodKey, semconv.HTTPRouteKey, semconv.HTTPStatusCodeKey}
+
+// Middleware returns an iris handler starting a server span for every
+// request, continuing the trace propagated by the caller. Register it with
+// app.Use so it runs after routing: spans are named after the matched route
+// template, e.g. "GET /user/{id}".
+//
+// Handlers registered with app.Use only run for requests that matched a
+// route, and this version of iris has no app.UseRouter. To also trace the
+// others, e.g. 404s, register the same handler for error codes, followed by
+// a handler writing the response:
+//
+//	traced := iristrace.Middleware()
+//	app.Use(traced, iristrace.Recover())
+//	app.OnAnyErrorCode(traced, func(ctx iris.Context) {
+//		ctx.WriteString(http.StatusText(ctx.GetStatusCode()))
+//	})
+//
+// Requests that matched no route are named after their method only, e.g.
+// "GET", and have no http.route attribute. Requests already traced before
+// their error code handlers run are not traced twice.
+//
+// The status code and response size are recorded once the rest of the
+// handler chain returned. 5xx responses and errors reported with
+// RecordError mark the span as failed. The duration and body sizes of the
+// request are recorded as http.server.* metrics with the method, route and
+// status code of the span.
+func Middleware(opts ...Option) iris.Handler {
+	cfg := newConfig(opts...)
+
+	return func(ctx iris.Context) {
+		req := ctx.Request()
+		if ctx.Values().GetBoolDefault(tracedKey, false) || !cfg.traced(req) {
+			ctx.Next()
+			return
+		}
+		ctx.Values().Set(tracedKey, true)
+
+		parent := cfg.Propagators.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		parent = cfg.Baggage.Apply(parent, req.RemoteAddr)
+
+		route, name := routeOf(ctx), req.Method
+		if route != "" {
+			name += " " + route
+		}
+		attrs := semconv.NetAttributesFromHTTPRequest("tcp", req)
+		attrs = append(attrs, semconv.EndUserAttributesFromHTTPRequest(req)...)
+		attrs = append(attrs, semconv.HTTPServerAttributesFromHTTPRequest(cfg.ServerName, route, req)...)
+		attrs = append(attrs, cfg.headerAttrs(req.Header)...)
+		attrs = append(attrs, cfg.Baggage.Attributes(parent)...)
+
+		spanCtx, span := cfg.Tracer.Start(
+			parent,
+			name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		ctx.ResetRequest(req.WithContext(spanCtx))
+
+		start := time.Now()
+		ctx.Next()
+		elapsed := time.Since(start)
+
+		status := ctx.GetStatusCode()
+		statusAttrs := semconv.HTTPAttributesFromHTTPStatusCode(status)
+		span.SetAttributes(statusAttrs...)
+		written := ctx.ResponseWriter().Written()
+		if written > 0 {
+			span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int(written))
+		}
+
+		metricAttrs := metrics.Select(append(attrs, statusAttrs...), metricKeys...)
+		cfg.Duration.RecordDuration(spanCtx, elapsed, metricAttrs...)
+		if req.ContentLength >= 0 {
+			cfg.RequestSize.Record(spanCtx, float64(req.ContentLength), metricAttrs...)
+		}
+		if written < 0 {
+			// Nothing was written.
+			written = 0
+		}
+		cfg.ResponseSize.Record(spanCtx, float64(written), metricAttrs...)
+		if status >= http.StatusInternalServerError && !ctx.Values().GetBoolDefault(failedKey, false) {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// RecordError records err on the server span of the request ctx serves and
+// marks the span as failed, whatever status code is eventually written.
+func RecordError(ctx iris.Context, err error) {
+	if err == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx.Request().Context())
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	ctx.Values().Set(failedKey, true)
+}
+
+// headerAttrs returns the redacted request headers cfg records.
+func (cfg *config) headerAttrs(header http.Header) []attribute.KeyValue {
+	if len(cfg.RequestHeaders) == 0 {
+		return nil
+	}
+	r := cfg.Redactor
+	if !cfg.RedactorSet {
+		r = redact.Global()
+	}
+
+	var attrs []attribute.KeyValue
+	for _, name := range cfg.RequestHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		key := "http.request.header." + strings.ReplaceAll(strings.ToLower(name), "-", "_")
+		attrs = append(attrs, attribute.String(key, r.Header(name, strings.Join(values, ","))))
+	}
+	return attrs
+}
+
+// routeOf returns the path template of the route ctx matched, or "" when
+// no route matched; the request path would give spans and metrics an
+// unbounded number of names.
+func routeOf(ctx iris.Context) string {
+	if r := ctx.GetCurrentRoute(); r != nil {
+		return r.Path()
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package iristrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"tracing/baggagepolicy"
+	"tracing/redact"
+	"tracing/spantest"
+)
+
+var propagators = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// newApp returns an application traced by the middleware configured by
+// opts.
+func newApp(t *testing.T, opts ...Option) *iris.Application {
+	t.Helper()
+
+	app := iris.New()
+	traced := Middleware(opts...)
+	app.Use(traced, Recover())
+	app.OnAnyErrorCode(traced, func(ctx iris.Context) {
+		_, _ = ctx.WriteString(http.StatusText(ctx.GetStatusCode()))
+	})
+	app.Get("/user/{id}", func(ctx iris.Context) {
+		_, _ = ctx.WriteString("user " + ctx.Params().Get("id"))
+	})
+	app.Get("/fail", func(ctx iris.Context) {
+		RecordError(ctx, errors.New("no database"))
+		ctx.StatusCode(http.StatusServiceUnavailable)
+	})
+	app.Get("/panic", func(iris.Context) {
+		panic("boom")
+	})
+	app.Get("/health", func(ctx iris.Context) {})
+	if err := app.Build(); err != nil {
+		t.Fatalf("build app: %v", err)
+	}
+	return app
+}
+
+func serve(app *iris.Application, ctx context.Context, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	propagators.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := spantest.New(t)
+	app := newApp(t, WithTracerProvider(rec.TracerProvider()), WithPropagators(propagators), WithFilter(SkipHealthChecks))
+
+	ctx, parent := rec.TracerProvider().Tracer("test").Start(context.Background(), "parent")
+	for _, path := range []string{"/user/42", "/fail", "/panic", "/health"} {
+		serve(app, ctx, path)
+	}
+	parent.End()
+
+	rec.Len(4)
+	p := rec.Span("parent")
+	rec.Span("GET /user/{id}").
+		HasKind(trace.SpanKindServer).
+		HasParent(p).
+		HasRemoteParent().
+		HasAttribute(semconv.HTTPRouteKey.String("/user/{id}")).
+		HasAttribute(semconv.HTTPTargetKey.String("/user/42")).
+		HasAttribute(semconv.HTTPStatusCodeKey.Int(http.StatusOK)).
+		HasAttribute(semconv.HTTPResponseContentLengthKey.Int(len("user 42"))).
+		HasStatus(codes.Unset)
+	rec.Span("GET /fail").
+		HasAttribute(semconv.HTTPStatusCodeKey.Int(http.StatusServiceUnavailable)).
+		HasStatus(codes.Error).
+		HasStatusMessage("no database").
+		HasEvent(semconv.ExceptionEventName, semconv.ExceptionMessageKey.String("no database"))
+	rec.Span("GET /panic").
+		HasAttribute(semconv.HTTPStatusCodeKey.Int(http.StatusInternalServerError)).
+		HasStatus(codes.Error).
+		HasEvent(semconv.ExceptionEventName, semconv.ExceptionMessageKey.String("boom"))
+}
+
+func TestMiddlewareBaggagePolicy(t *testing.T) {
+	ctx := baggage.ContextWithValues(context.Background(),
+		attribute.String("username", "donuts"),
+		attribute.String("session", "secret"),
+	)
+	tests := []struct {
+		name     string
+		networks []string
+		want     bool
+	}{
+		{"trusted", []string{"10.0.0.0/8"}, true},
+		{"untrusted", []string{"192.168.0.0/16"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks, err := baggagepolicy.ParseNetworks(tt.networks...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := spantest.New(t)
+			app := newApp(t,
+				WithTracerProvider(rec.TracerProvider()),
+				WithPropagators(propagators),
+				WithBaggagePolicy(baggagepolicy.New(
+					baggagepolicy.WithTrustedNetworks(networks...),
+					baggagepolicy.WithPromotedKeys("username"),
+				)),
+			)
+
+			serve(app, ctx, "/user/42")
+
+			span := rec.Span("GET /user/{id}").HasNoAttribute("session")
+			if tt.want {
+				span.HasAttribute(attribute.String("username", "donuts"))
+			} else {
+				span.HasNoAttribute("username")
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestHeaders(t *testing.T) {
+	prev := redact.Global()
+	t.Cleanup(func() { redact.SetGlobal(prev) })
+
+	rec := spantest.New(t)
+	app := newApp(t,
+		WithTracerProvider(rec.TracerProvider()),
+		WithRequestHeaders("Authorization", "X-Request-Id", "X-Missing"),
+	)
+	// The global redactor is looked up when requests are served, not when
+	// the middleware is built.
+	redact.SetGlobal(redact.New(redact.WithFields(redact.Mask, "authorization")))
+
+	req := httptest.NewRequest(http.MethodGet, "/user/42", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Add("X-Request-Id", "a")
+	req.Header.Add("X-Request-Id", "b")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec.Span("GET /user/{id}").
+		HasAttribute(attribute.String("http.request.header.authorization", "[REDACTED]")).
+		HasAttribute(attribute.String("http.request.header.x_request_id", "a,b")).
+		HasNoAttribute("http.request.header.x_missing")
+}
+
+func TestMiddlewareUnmatchedRoutes(t *testing.T) {
+	rec := spantest.New(t)
+	app := newApp(t, WithTracerProvider(rec.TracerProvider()), WithPropagators(propagators))
+
+	ctx, parent := rec.TracerProvider().Tracer("test").Start(context.Background(), "parent")
+	w := serve(app, ctx, "/no/such/route")
+	parent.End()
+
+	if w.Code != http.StatusNotFound || w.Body.String() != "Not Found" {
+		t.Errorf("response = %d %q, want 404 from the error code handler", w.Code, w.Body.String())
+	}
+	rec.Len(2)
+	rec.Span("GET").
+		HasKind(trace.SpanKindServer).
+		HasParent(rec.Span("parent")).
+		HasNoAttribute(semconv.HTTPRouteKey).
+		HasAttribute(semconv.HTTPTargetKey.String("/no/such/route")).
+		HasAttribute(semconv.HTTPStatusCodeKey.Int(http.StatusNotFound)).
+		HasStatus(codes.Unset)
+
+	// Error code handlers of matched routes do not start a second span.
+	rec.Reset()
+	w = serve(app, context.Background(), "/panic")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("response = %d, want 500", w.Code)
+	}
+	rec.Len(1)
+	rec.Span("GET /panic").HasStatus(codes.Error)
+}
@@ -0,0 +1,31 @@
+package iristrace
+
+import (
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel/trace"
+
+	"tracing/internal/recovery"
+)
+
+// Recover returns an iris handler turning panics of the rest of the
+// handler chain into 500 responses. The panic value and stack trace are
+// recorded as an exception event on the server span, so register it after
+// Middleware:
+//
+//	app.Use(Middleware(), Recover())
+func Recover() iris.Handler {
+	return func(ctx iris.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				recovery.RecordPanic(trace.SpanFromContext(ctx.Request().Context()), r)
+				ctx.Values().Set(failedKey, true)
+				ctx.StatusCode(http.StatusInternalServerError)
+				ctx.StopExecution()
+			}
+		}()
+
+		ctx.Next()
+	}
+}
@@ -2,251 +2,87 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"log"
-	"net"
-	"strings"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 
+	"tracing/grpctrace"
 	tracing "tracing/proto"
+	"tracing/tracelog"
 	"tracing/tracer"
-
-	"go.opentelemetry.io/contrib"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/propagation"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	"go.opentelemetry.io/otel/semconv"
-	"go.opentelemetry.io/otel/trace"
-
-	"go.opentelemetry.io/otel/attribute"
-	grpc_codes "google.golang.org/grpc/codes"
-)
-
-const (
-	defaultTracerName = "go.opentelemetry.io/contrib/instrumentation/github.com/Shopify/sarama/otelsarama"
-
-	kafkaPartitionKey = attribute.Key("messaging.kafka.partition")
 )
 
-type metadataSupplier struct {
-	metadata *metadata.MD
-}
-
-// assert that metadataSupplier implements the TextMapCarrier interface
-var _ propagation.TextMapCarrier = &metadataSupplier{}
-
-func (s *metadataSupplier) Get(key string) string {
-	values := s.metadata.Get(key)
-	if len(values) == 0 {
-		return ""
-	}
-	return values[0]
-}
-
-func (s *metadataSupplier) Set(key string, value string) {
-	s.metadata.Set(key, value)
-}
-
-func (s *metadataSupplier) Keys() []string {
-	out := make([]string, 0, len(*s.metadata))
-	for key := range *s.metadata {
-		out = append(out, key)
-	}
-	return out
+func main() {
+	ClientOpne()
 }
 
 // ClientOpne -
 func ClientOpne() {
 
-	tp, err := tracer.TracerProvider("http://localhost:14268/api/traces")
+	cfg, err := tracer.LoadConfig(os.Getenv(tracer.ConfigFileEnv))
 	if err != nil {
 		panic(err)
 	}
 
+	tp, shutdown, err := tracer.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	logger := tracelog.New(os.Stdout)
+
+	traceOpts := []grpctrace.Option{grpctrace.WithTracerProvider(tp), grpctrace.WithLegacyAttributes()}
+
 	cc, err := grpc.Dial(
 		"localhost:50051",
 		grpc.WithInsecure(),
 		grpc.WithUnaryInterceptor(
-			UnaryServerInterceptor(tp),
+			grpctrace.UnaryClientInterceptor(traceOpts...),
+		),
+		grpc.WithStreamInterceptor(
+			grpctrace.StreamClientInterceptor(traceOpts...),
 		),
 	)
 
 	if err != nil {
-		log.Fatalf("Error connecting: %v", err)
+		logger.Error(context.Background(), "failed to connect", "error", err)
+		// Export what was recorded so far before giving up.
+		if _, err := shutdown(context.Background()); err != nil {
+			logger.Error(context.Background(), "shutdown", "error", err)
+		}
+		os.Exit(1)
 	}
-	defer cc.Close()
 
 	c := tracing.NewHelloServiceClient(cc)
 
-	for i := 0; i < 9999; i++ {
-		time.Sleep(time.Second * 1)
-		c.Pin(context.Background(), &tracing.Request{Id: "123"})
-	}
-
-}
-
-func UnaryServerInterceptor(tp *tracesdk.TracerProvider, opts ...Option) grpc.UnaryClientInterceptor {
-	return func(ctx context.Context, method string, req, resp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
-		requestMetadata, _ := metadata.FromOutgoingContext(ctx)
-		metadataCopy := requestMetadata.Copy()
-
-		name, attr := spanInfo(method, cc.Target(), req)
-		tr := tp.Tracer(method)
-		var span trace.Span
-		ctx, span = tr.Start(
-			context.Background(),
-			name,
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithAttributes(attr...),
-		)
-
-		// set TraceID
-		span.SetAttributes(attribute.KeyValue{
-			Key:   attribute.Key("TraceID"),
-			Value: attribute.StringValue(span.SpanContext().TraceID().String()),
-		})
-		defer span.End()
-
-		Inject(ctx, &metadataCopy, opts...)
-		ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
-
-		err := invoker(ctx, method, req, resp, cc, callOpts...)
-
-		if err != nil {
-			s, _ := status.FromError(err)
-			span.SetStatus(codes.Error, s.Message())
-			span.SetAttributes(statusCodeAttr(s.Code()))
-
-			return err
+	done, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+
+		for i := 0; i < 9999; i++ {
+			select {
+			case <-done.Done():
+				return
+			case <-time.After(time.Second * 1):
+			}
+
+			ctx, cancel := context.WithTimeout(done, time.Second)
+			// Calls cut short by the shutdown are not worth reporting.
+			if _, err := c.Pin(ctx, &tracing.Request{Id: "123"}); err != nil && done.Err() == nil {
+				logger.Error(ctx, "pin", "error", err)
+			}
+			cancel()
 		}
+	}()
 
-		span.SetAttributes(statusCodeAttr(grpc_codes.OK))
-
-		return nil
-	}
-}
-
-func spanInfo(fullMethod, peerAddress string, req interface{}) (string, []attribute.KeyValue) {
-	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
-	name, mAttrs := parseFullMethod(fullMethod)
-	attrs = append(attrs, mAttrs...)
-	attrs = append(attrs, peerAttr(peerAddress)...)
-
-	// requestMetadata
-	j, _ := json.Marshal(req)
-	attrs = append(attrs, attribute.KeyValue{
-		Key:   attribute.Key("request"),
-		Value: attribute.StringValue(string(j)),
-	})
-
-	return name, attrs
-}
-
-func parseFullMethod(fullMethod string) (string, []attribute.KeyValue) {
-	name := strings.TrimLeft(fullMethod, "/")
-	parts := strings.SplitN(name, "/", 2)
-	if len(parts) != 2 {
-		// Invalid format, does not follow `/package.service/method`.
-		return name, []attribute.KeyValue(nil)
-	}
-
-	var attrs []attribute.KeyValue
-	if service := parts[0]; service != "" {
-		attrs = append(attrs, semconv.RPCServiceKey.String(service))
-	}
-	if method := parts[1]; method != "" {
-		attrs = append(attrs, semconv.RPCMethodKey.String(method))
-	}
-	return name, attrs
-}
-
-func peerAttr(addr string) []attribute.KeyValue {
-	host, port, err := net.SplitHostPort(addr)
-	if err != nil {
-		return []attribute.KeyValue(nil)
-	}
-
-	if host == "" {
-		host = "127.0.0.1"
-	}
-
-	return []attribute.KeyValue{
-		semconv.NetPeerIPKey.String(host),
-		semconv.NetPeerPortKey.String(port),
-	}
-}
-
-func Inject(ctx context.Context, metadata *metadata.MD, opts ...Option) {
-	c := newConfig(opts...)
-	c.Propagators.Inject(ctx, &metadataSupplier{
-		metadata: metadata,
-	})
-}
-
-type config struct {
-	TracerProvider trace.TracerProvider
-	Propagators    propagation.TextMapPropagator
-
-	Tracer trace.Tracer
-}
-
-func newConfig(opts ...Option) config {
-	cfg := config{
-		Propagators:    otel.GetTextMapPropagator(),
-		TracerProvider: otel.GetTracerProvider(),
-	}
-	for _, opt := range opts {
-		opt(&cfg)
-	}
-
-	cfg.Tracer = cfg.TracerProvider.Tracer(
-		defaultTracerName,
-		trace.WithInstrumentationVersion(contrib.SemVersion()),
+	stats, err := tracer.Graceful(done, time.Duration(cfg.ShutdownTimeout), shutdown,
+		func(context.Context) error {
+			cancel()
+			return cc.Close()
+		},
 	)
-
-	return cfg
-}
-
-// Option specifies instrumentation configuration options.
-type Option func(*config)
-
-// WithTracerProvider specifies a tracer provider to use for creating a tracer.
-// If none is specified, the global provider is used.
-func WithTracerProvider(provider trace.TracerProvider) Option {
-	return func(cfg *config) {
-		cfg.TracerProvider = provider
-	}
-}
-
-// WithPropagators specifies propagators to use for extracting
-// information from the HTTP requests. If none are specified, global
-// ones will be used.
-func WithPropagators(propagators propagation.TextMapPropagator) Option {
-	return func(cfg *config) {
-		cfg.Propagators = propagators
-	}
-}
-
-func Extract(ctx context.Context, metadata *metadata.MD, opts ...Option) ([]attribute.KeyValue, trace.SpanContext) {
-	c := newConfig(opts...)
-	ctx = c.Propagators.Extract(ctx, &metadataSupplier{
-		metadata: metadata,
-	})
-
-	attributeSet := baggage.Set(ctx)
-
-	return (&attributeSet).ToSlice(), trace.SpanContextFromContext(ctx)
-}
-
-func statusCodeAttr(c grpc_codes.Code) attribute.KeyValue {
-	return attribute.KeyValue{
-		Key:   attribute.Key("statusCode"),
-		Value: attribute.Int64Value(int64(c)),
+	if err != nil {
+		logger.Error(done, "shutdown", "error", err)
 	}
+	logger.Info(done, "shutdown complete", "exported", stats.Exported, "dropped", stats.Dropped)
 }
@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Labels of the exemplars attached to histogram buckets.
+const (
+	TraceIDLabel = "trace_id"
+	SpanIDLabel  = "span_id"
+)
+
+// MaxExemplarSeries is the number of series of a histogram Exemplars keeps
+// exemplars for. Once it is reached, recording a new series forgets the
+// series recorded least recently, so label sets of unbounded cardinality
+// cannot grow the store without bound.
+const MaxExemplarSeries = 1000
+
+// Exemplars keeps, for every bucket of every histogram series, the trace of
+// the latest sampled value that fell into it. The OpenTelemetry Prometheus
+// exporter has no notion of exemplars, so they are attached to the
+// gathered metric families before they are written. A nil *Exemplars keeps
+// nothing.
+type Exemplars struct {
+	mu sync.Mutex
+	// series maps a sanitized metric name to its series.
+	series    map[string]*histogramExemplars
+	maxSeries int
+
+	now func() time.Time
+}
+
+// histogramExemplars holds the series of a histogram, by label set and from
+// the most to the least recently recorded.
+type histogramExemplars struct {
+	byLabels map[string]*list.Element
+	recent   *list.List
+}
+
+// exemplarSeries holds the exemplars of a histogram series: one per bucket,
+// the last one for values above every boundary.
+type exemplarSeries struct {
+	key       string
+	labels    map[string]string
+	bounds    []float64
+	exemplars []*dto.Exemplar
+}
+
+// NewExemplars returns an empty exemplar store keeping MaxExemplarSeries
+// series per histogram.
+func NewExemplars() *Exemplars {
+	return &Exemplars{series: map[string]*histogramExemplars{}, maxSeries: MaxExemplarSeries, now: time.Now}
+}
+
+func (e *Exemplars) record(name string, bounds []float64, value float64, attrs []attribute.KeyValue, sc trace.SpanContext) {
+	if e == nil {
+		return
+	}
+
+	labels := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		labels[sanitize(string(kv.Key))] = kv.Value.Emit()
+	}
+	// Buckets hold values strictly below their upper bound, as they do in
+	// the OpenTelemetry histogram aggregator.
+	bucket := sort.Search(len(bounds), func(i int) bool { return value < bounds[i] })
+
+	exemplar := &dto.Exemplar{
+		Label: []*dto.LabelPair{
+			{Name: stringPtr(TraceIDLabel), Value: stringPtr(sc.TraceID().String())},
+			{Name: stringPtr(SpanIDLabel), Value: stringPtr(sc.SpanID().String())},
+		},
+		Value: &value,
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	exemplar.Timestamp = timestamppb.New(e.now())
+	series := e.lookupLocked(name, labels, bounds)
+	// Exemplars are replaced, never modified, so gathered metric families
+	// may share them.
+	series.exemplars[bucket] = exemplar
+}
+
+func (e *Exemplars) lookupLocked(name string, labels map[string]string, bounds []float64) *exemplarSeries {
+	h := e.series[name]
+	if h == nil {
+		h = &histogramExemplars{byLabels: map[string]*list.Element{}, recent: list.New()}
+		e.series[name] = h
+	}
+
+	key := labelsKey(labels)
+	if elem, ok := h.byLabels[key]; ok {
+		h.recent.MoveToFront(elem)
+		return elem.Value.(*exemplarSeries)
+	}
+
+	if e.maxSeries > 0 && h.recent.Len() >= e.maxSeries {
+		oldest := h.recent.Back()
+		h.recent.Remove(oldest)
+		delete(h.byLabels, oldest.Value.(*exemplarSeries).key)
+	}
+	s := &exemplarSeries{
+		key:       key,
+		labels:    labels,
+		bounds:    bounds,
+		exemplars: make([]*dto.Exemplar, len(bounds)+1),
+	}
+	h.byLabels[key] = h.recent.PushFront(s)
+	return s
+}
+
+// labelsKey returns a string identifying the label set labels.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		// Names are sanitized and values quoted, so no two label sets
+		// share a key.
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// attach sets the exemplars of the buckets of the histograms in families.
+// A gathered series carries the labels of the resource in addition to the
+// recorded attributes, so series match when the attributes are a subset of
+// its labels.
+func (e *Exemplars) attach(families []*dto.MetricFamily) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_HISTOGRAM {
+			continue
+		}
+		h := e.series[family.GetName()]
+		if h == nil {
+			continue
+		}
+		for _, m := range family.Metric {
+			labels := make(map[string]string, len(m.Label))
+			for _, l := range m.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			// The most recently recorded series attaches last, so its
+			// exemplars win where series overlap.
+			for elem := h.recent.Back(); elem != nil; elem = elem.Prev() {
+				if s := elem.Value.(*exemplarSeries); containsLabels(labels, s.labels) {
+					s.attachTo(m.GetHistogram())
+				}
+			}
+		}
+	}
+}
+
+func (s *exemplarSeries) attachTo(h *dto.Histogram) {
+	if h == nil {
+		return
+	}
+	for _, b := range h.Bucket {
+		i := sort.SearchFloat64s(s.bounds, b.GetUpperBound())
+		if i < len(s.bounds) && s.bounds[i] == b.GetUpperBound() && s.exemplars[i] != nil {
+			b.Exemplar = s.exemplars[i]
+		}
+	}
+}
+
+// containsLabels reports whether every label of sub is in labels.
+func containsLabels(labels, sub map[string]string) bool {
+	for k, v := range sub {
+		if w, ok := labels[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// sanitize turns an OpenTelemetry instrument or attribute name into the
+// Prometheus name the exporter writes, e.g. "rpc.server.duration" into
+// "rpc_server_duration".
+func sanitize(s string) string {
+	if s == "" {
+		return s
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+	if unicode.IsDigit(rune(s[0])) {
+		s = "key_" + s
+	}
+	if s[0] == '_' {
+		s = "key" + s
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	otelprometheus "go.opentelemetry.io/otel/exporters/metric/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Exporter serves the metrics recorded with its MeterProvider to Prometheus
+// scrapes, in the OpenMetrics format when the scraper accepts it so
+// histogram buckets carry their exemplars.
+type Exporter struct {
+	provider  *meterProvider
+	exemplars *Exemplars
+	handler   http.Handler
+}
+
+var _ http.Handler = (*Exporter)(nil)
+
+type exporterConfig struct {
+	Resource *resource.Resource
+	Registry *prometheus.Registry
+}
+
+// ExporterOption configures an Exporter.
+type ExporterOption func(*exporterConfig)
+
+// WithResource adds the attributes of res, e.g. service.name, as labels to
+// every series.
+func WithResource(res *resource.Resource) ExporterOption {
+	return func(cfg *exporterConfig) {
+		cfg.Resource = res
+	}
+}
+
+// WithRegistry specifies the registry the metrics are registered with and
+// gathered from, e.g. to serve them next to the Go runtime metrics. A new
+// registry is used by default.
+func WithRegistry(registry *prometheus.Registry) ExporterOption {
+	return func(cfg *exporterConfig) {
+		cfg.Registry = registry
+	}
+}
+
+// NewExporter returns an Exporter whose metrics are computed on every
+// scrape.
+func NewExporter(opts ...ExporterOption) (*Exporter, error) {
+	cfg := exporterConfig{Registry: prometheus.NewRegistry()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctrl := controller.New(
+		processor.New(selector{}, export.CumulativeExportKindSelector(), processor.WithMemory(true)),
+		controller.WithResource(cfg.Resource),
+		// Collect on every scrape rather than serving a cached collection.
+		controller.WithCollectPeriod(0),
+	)
+	if _, err := otelprometheus.NewExporter(otelprometheus.Config{Registry: cfg.Registry}, ctrl); err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+
+	e := &Exporter{exemplars: NewExemplars()}
+	e.provider = &meterProvider{MeterProvider: ctrl.MeterProvider(), exemplars: e.exemplars}
+	e.handler = promhttp.HandlerFor(
+		prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			families, err := cfg.Registry.Gather()
+			e.exemplars.attach(families)
+			return families, err
+		}),
+		promhttp.HandlerOpts{EnableOpenMetrics: true},
+	)
+	return e, nil
+}
+
+// MeterProvider returns the provider whose metrics e serves. Pass it to the
+// WithMeterProvider option of grpctrace and iristrace, or register e with
+// Install.
+func (e *Exporter) MeterProvider() metric.MeterProvider {
+	return e.provider
+}
+
+// ServeHTTP serves a scrape.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.handler.ServeHTTP(w, r)
+}
+
+// meterProvider tells NewMeter where the exemplars of the provider's
+// histograms are kept.
+type meterProvider struct {
+	metric.MeterProvider
+	exemplars *Exemplars
+}
+
+// selector aggregates value recorders into histograms with the boundaries
+// of their unit, and every other instrument as the simple selectors do.
+type selector struct{}
+
+func (selector) AggregatorFor(descriptor *metric.Descriptor, aggPtrs ...*export.Aggregator) {
+	if descriptor.InstrumentKind() != metric.ValueRecorderInstrumentKind {
+		simple.NewWithInexpensiveDistribution().AggregatorFor(descriptor, aggPtrs...)
+		return
+	}
+	aggs := histogram.New(len(aggPtrs), descriptor, histogram.WithExplicitBoundaries(boundaries(descriptor.Unit())))
+	for i := range aggPtrs {
+		*aggPtrs[i] = &aggs[i]
+	}
+}
+
+var (
+	globalMu       sync.RWMutex
+	globalExporter *Exporter
+)
+
+// Install registers the MeterProvider of e as the global one, used by
+// instrumentation that is not given one explicitly, and keeps the exemplars
+// of its histograms with e.
+func Install(e *Exporter) {
+	globalMu.Lock()
+	globalExporter = e
+	globalMu.Unlock()
+
+	global.SetMeterProvider(e.MeterProvider())
+}
+
+// globalExemplars returns the exemplars of the Exporter registered with
+// Install, or nil if none is.
+func globalExemplars() *Exemplars {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	if globalExporter == nil {
+		return nil
+	}
+	return globalExporter.exemplars
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/unit"
+)
+
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d: %s", rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+func spanContext(traceID byte, sampled bool) context.Context {
+	cfg := trace.SpanContextConfig{
+		TraceID: trace.TraceID{traceID},
+		SpanID:  trace.SpanID{traceID},
+	}
+	if sampled {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(cfg))
+}
+
+func TestExemplars(t *testing.T) {
+	e, err := NewExporter(WithResource(resource.NewWithAttributes(attribute.String("service.name", "test"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewMeter(e.MeterProvider(), "test").Histogram("test.duration", "", unit.Milliseconds)
+	route := attribute.String("http.route", "/ping")
+
+	h.Record(spanContext(1, true), 3, route)
+	// A value equal to a boundary belongs to the next bucket.
+	h.Record(spanContext(2, true), 10, route)
+	// Unsampled traces cannot be looked up, so they are no exemplars.
+	h.Record(spanContext(3, false), 4, route)
+	h.Record(spanContext(4, true), 3, attribute.String("http.route", "/other"))
+
+	body := scrape(t, e)
+	for _, want := range []string{
+		`test_duration_bucket{http_route="/ping",service_name="test",le="5.0"} 2 # {trace_id="01000000000000000000000000000000",span_id="0100000000000000"} 3.0`,
+		`test_duration_bucket{http_route="/ping",service_name="test",le="25.0"} 3 # {trace_id="02000000000000000000000000000000",span_id="0200000000000000"} 10.0`,
+		`test_duration_bucket{http_route="/other",service_name="test",le="5.0"} 1 # {trace_id="04000000000000000000000000000000",span_id="0400000000000000"} 3.0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape lacks %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `trace_id="03`) {
+		t.Errorf("scrape has an exemplar of an unsampled trace:\n%s", body)
+	}
+}
+
+func TestExemplarSeriesAreCapped(t *testing.T) {
+	e, err := NewExporter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.exemplars.maxSeries = 2
+	h := NewMeter(e.MeterProvider(), "test").Histogram("test.duration", "", unit.Milliseconds)
+
+	h.Record(spanContext(1, true), 3, attribute.String("http.route", "/a"))
+	h.Record(spanContext(2, true), 3, attribute.String("http.route", "/b"))
+	// Recording /a again makes /b the least recently recorded series, so
+	// /c replaces it.
+	h.Record(spanContext(3, true), 3, attribute.String("http.route", "/a"))
+	h.Record(spanContext(4, true), 3, attribute.String("http.route", "/c"))
+
+	if n := e.exemplars.series["test_duration"].recent.Len(); n != 2 {
+		t.Errorf("kept %d series, want 2", n)
+	}
+	body := scrape(t, e)
+	for _, want := range []string{
+		`test_duration_bucket{http_route="/a",le="5.0"} 2 # {trace_id="03000000000000000000000000000000",span_id="0300000000000000"} 3.0`,
+		`test_duration_bucket{http_route="/c",le="5.0"} 1 # {trace_id="04000000000000000000000000000000",span_id="0400000000000000"} 3.0`,
+		// The series itself is still exported, without its exemplar.
+		`test_duration_bucket{http_route="/b",le="5.0"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape lacks %s:\n%s", want, body)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	attrs := []attribute.KeyValue{
+		attribute.String("a", "1"),
+		attribute.String("b", "2"),
+		attribute.String("a", "3"),
+	}
+	got := Select(attrs, "b", "a", "c")
+	if len(got) != 2 || got[0] != attrs[1] || got[1] != attrs[2] {
+		t.Errorf("Select = %v, want [b=2 a=3]", got)
+	}
+}
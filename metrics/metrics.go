@@ -0,0 +1,132 @@
+// Package metrics records the RED metrics (rate, errors and duration) of
+// traced requests as OpenTelemetry histograms and exposes them on a
+// Prometheus scrape endpoint. Every histogram bucket carries the trace of
+// its latest sampled value as an exemplar, so a latency spike on a
+// dashboard leads straight to a trace.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/contrib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// Names of the instruments recorded by grpctrace and iristrace. They follow
+// the OpenTelemetry semantic conventions for RPC and HTTP metrics.
+const (
+	RPCServerDuration     = "rpc.server.duration"
+	RPCServerRequestSize  = "rpc.server.request.size"
+	RPCServerResponseSize = "rpc.server.response.size"
+	RPCClientDuration     = "rpc.client.duration"
+	RPCClientRequestSize  = "rpc.client.request.size"
+	RPCClientResponseSize = "rpc.client.response.size"
+
+	HTTPServerDuration     = "http.server.duration"
+	HTTPServerRequestSize  = "http.server.request.size"
+	HTTPServerResponseSize = "http.server.response.size"
+)
+
+// Bucket boundaries of the histograms, chosen by the unit of the
+// instrument.
+var (
+	// DurationBoundaries are the boundaries of durations, in milliseconds.
+	DurationBoundaries = []float64{1, 2.5, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 10000}
+	// SizeBoundaries are the boundaries of message sizes, in bytes.
+	SizeBoundaries = []float64{0, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+)
+
+// boundaries returns the bucket boundaries of instruments measuring u.
+func boundaries(u unit.Unit) []float64 {
+	if u == unit.Bytes {
+		return SizeBoundaries
+	}
+	return DurationBoundaries
+}
+
+// Meter creates the histograms of an instrumentation library.
+type Meter struct {
+	meter     metric.Meter
+	exemplars *Exemplars
+}
+
+// NewMeter returns the Meter of instrumentationName. Histograms keep their
+// exemplars with the provider when it was returned by an Exporter, and
+// with the global Exporter otherwise.
+func NewMeter(provider metric.MeterProvider, instrumentationName string) Meter {
+	m := Meter{
+		meter: provider.Meter(instrumentationName, metric.WithInstrumentationVersion(contrib.SemVersion())),
+	}
+	if p, ok := provider.(*meterProvider); ok {
+		m.exemplars = p.exemplars
+	}
+	return m
+}
+
+// Histogram returns a histogram of values measured in u, either
+// unit.Milliseconds or unit.Bytes.
+func (m Meter) Histogram(name, description string, u unit.Unit) Histogram {
+	recorder, err := m.meter.NewFloat64ValueRecorder(name, metric.WithDescription(description), metric.WithUnit(u))
+	if err != nil {
+		// The recorder returned with the error records nothing.
+		otel.Handle(err)
+	}
+	return Histogram{
+		name:      sanitize(name),
+		bounds:    boundaries(u),
+		recorder:  recorder,
+		exemplars: m.exemplars,
+	}
+}
+
+// Histogram records values on an OpenTelemetry value recorder and keeps the
+// trace of the latest sampled value of every bucket as its exemplar.
+type Histogram struct {
+	name      string
+	bounds    []float64
+	recorder  metric.Float64ValueRecorder
+	exemplars *Exemplars
+}
+
+// Record records value with attrs. The span of ctx, if sampled, becomes the
+// exemplar of the value's bucket.
+func (h Histogram) Record(ctx context.Context, value float64, attrs ...attribute.KeyValue) {
+	h.recorder.Record(ctx, value, attrs...)
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return
+	}
+	exemplars := h.exemplars
+	if exemplars == nil {
+		exemplars = globalExemplars()
+	}
+	exemplars.record(h.name, h.bounds, value, attrs, sc)
+}
+
+// RecordDuration records d in milliseconds.
+func (h Histogram) RecordDuration(ctx context.Context, d time.Duration, attrs ...attribute.KeyValue) {
+	h.Record(ctx, float64(d)/float64(time.Millisecond), attrs...)
+}
+
+// Select returns the attributes of attrs with one of keys, in the order of
+// keys. Instrumentation passes it the attributes of a span so metrics share
+// them without inheriting high cardinality ones such as peer addresses.
+// The last attribute with a key wins, as it does on spans.
+func Select(attrs []attribute.KeyValue, keys ...attribute.Key) []attribute.KeyValue {
+	selected := make([]attribute.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		for i := len(attrs) - 1; i >= 0; i-- {
+			if attrs[i].Key == key {
+				selected = append(selected, attrs[i])
+				break
+			}
+		}
+	}
+	return selected
+}
@@ -0,0 +1,96 @@
+package propagators
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OpenCensusBinaryKey is the gRPC metadata key of the OpenCensus binary
+// format.
+const OpenCensusBinaryKey = "grpc-trace-bin"
+
+// Layout of the OpenCensus binary format: a version byte, then fields made
+// of an ID byte and a fixed size value.
+const (
+	binaryVersion = 0
+
+	binaryTraceIDField = 0
+	binarySpanIDField  = 1
+	binaryOptionsField = 2
+
+	binaryLen = 1 + (1 + 16) + (1 + 8) + (1 + 1)
+)
+
+// OpenCensusBinary propagates the span context in the binary format
+// OpenCensus gRPC instrumentation uses, for interop with services that have
+// not moved to OpenTelemetry. The value is raw bytes, so it is only fit
+// for gRPC metadata, which encodes the values of "-bin" keys itself.
+type OpenCensusBinary struct{}
+
+var _ propagation.TextMapPropagator = OpenCensusBinary{}
+
+// Inject sets grpc-trace-bin to the span context of ctx, if it is valid.
+func (OpenCensusBinary) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	carrier.Set(OpenCensusBinaryKey, string(EncodeBinary(sc)))
+}
+
+// Extract returns ctx with the remote span context held by grpc-trace-bin,
+// if it holds a valid one.
+func (OpenCensusBinary) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	sc, ok := DecodeBinary([]byte(carrier.Get(OpenCensusBinaryKey)))
+	if !ok {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Fields returns grpc-trace-bin.
+func (OpenCensusBinary) Fields() []string {
+	return []string{OpenCensusBinaryKey}
+}
+
+// EncodeBinary encodes sc in the OpenCensus binary format.
+func EncodeBinary(sc trace.SpanContext) []byte {
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+
+	b := make([]byte, 0, binaryLen)
+	b = append(b, binaryVersion)
+	b = append(b, binaryTraceIDField)
+	b = append(b, traceID[:]...)
+	b = append(b, binarySpanIDField)
+	b = append(b, spanID[:]...)
+	b = append(b, binaryOptionsField, byte(sc.TraceFlags()&trace.FlagsSampled))
+	return b
+}
+
+// DecodeBinary decodes a span context in the OpenCensus binary format. Like
+// OpenCensus, it accepts missing trailing fields and ignores unknown ones
+// that follow, but reports false unless the result is valid.
+func DecodeBinary(b []byte) (trace.SpanContext, bool) {
+	if len(b) == 0 || b[0] != binaryVersion {
+		return trace.SpanContext{}, false
+	}
+	b = b[1:]
+
+	var cfg trace.SpanContextConfig
+	if len(b) >= 1+len(cfg.TraceID) && b[0] == binaryTraceIDField {
+		copy(cfg.TraceID[:], b[1:])
+		b = b[1+len(cfg.TraceID):]
+	}
+	if len(b) >= 1+len(cfg.SpanID) && b[0] == binarySpanIDField {
+		copy(cfg.SpanID[:], b[1:])
+		b = b[1+len(cfg.SpanID):]
+	}
+	if len(b) >= 2 && b[0] == binaryOptionsField {
+		cfg.TraceFlags = trace.TraceFlags(b[1]) & trace.FlagsSampled
+	}
+
+	sc := trace.NewSpanContext(cfg)
+	return sc, sc.IsValid()
+}
@@ -0,0 +1,30 @@
+//go:build go1.18
+// +build go1.18
+
+package propagators
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func FuzzDecodeBinary(f *testing.F) {
+	f.Add(openCensusBinary)
+	f.Add(openCensusBinary[:18])
+	f.Add([]byte{0, 1, 2})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sc, ok := DecodeBinary(b)
+		if !ok {
+			return
+		}
+		if !sc.IsValid() {
+			t.Fatalf("DecodeBinary(%x) = %v, reported valid", b, sc)
+		}
+		again, ok := DecodeBinary(EncodeBinary(sc))
+		if !ok || again.TraceID() != sc.TraceID() || again.SpanID() != sc.SpanID() || again.TraceFlags() != sc.TraceFlags()&trace.FlagsSampled {
+			t.Fatalf("round trip of %v = %v, %v", sc, again, ok)
+		}
+	})
+}
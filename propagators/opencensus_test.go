@@ -0,0 +1,43 @@
+package propagators
+
+import (
+	"bytes"
+	"testing"
+)
+
+// openCensusBinary is a span context encoded by OpenCensus'
+// propagation.Binary.
+var openCensusBinary = []byte{
+	0,
+	0, 0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36,
+	1, 0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7,
+	2, 1,
+}
+
+func TestDecodeBinary(t *testing.T) {
+	sc, ok := DecodeBinary(openCensusBinary)
+	if !ok {
+		t.Fatal("DecodeBinary reported an invalid span context")
+	}
+	if got, want := sc.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("TraceID = %s, want %s", got, want)
+	}
+	if got, want := sc.SpanID().String(), "00f067aa0ba902b7"; got != want {
+		t.Errorf("SpanID = %s, want %s", got, want)
+	}
+	if !sc.IsSampled() {
+		t.Error("span context is not sampled")
+	}
+	if got := EncodeBinary(sc); !bytes.Equal(got, openCensusBinary) {
+		t.Errorf("EncodeBinary = %x, want %x", got, openCensusBinary)
+	}
+
+	// Without options, the span context is not sampled.
+	if sc, ok := DecodeBinary(openCensusBinary[:27]); !ok || sc.IsSampled() {
+		t.Errorf("DecodeBinary without options = %v, %v, want a valid unsampled span context", sc, ok)
+	}
+	// Without a span ID, it is invalid.
+	if _, ok := DecodeBinary(openCensusBinary[:18]); ok {
+		t.Error("DecodeBinary accepted a span context without span ID")
+	}
+}
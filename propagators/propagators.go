@@ -0,0 +1,131 @@
+// Package propagators selects the formats trace context and baggage are
+// propagated in by name, and combines them so a service accepts every
+// format its callers use while sending only the ones its callees expect.
+package propagators
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Names of the propagation formats, as used by OTEL_PROPAGATORS.
+const (
+	// TraceContext is the W3C traceparent and tracestate headers.
+	TraceContext = "tracecontext"
+	// Baggage is the W3C baggage header.
+	Baggage = "baggage"
+	// B3 is the single b3 header of Zipkin.
+	B3 = "b3"
+	// B3Multi is the x-b3-* headers of Zipkin.
+	B3Multi = "b3multi"
+	// Jaeger is the uber-trace-id header.
+	Jaeger = "jaeger"
+	// XRay is the X-Amzn-Trace-Id header of AWS X-Ray.
+	XRay = "xray"
+	// OpenCensus is the grpc-trace-bin metadata of OpenCensus, see
+	// OpenCensusBinary. It is only fit for gRPC.
+	OpenCensus = "opencensus"
+)
+
+var formats = map[string]propagation.TextMapPropagator{
+	TraceContext: propagation.TraceContext{},
+	Baggage:      propagation.Baggage{},
+	B3:           b3.B3{InjectEncoding: b3.B3SingleHeader},
+	B3Multi:      b3.B3{InjectEncoding: b3.B3MultipleHeader},
+	Jaeger:       jaeger.Jaeger{},
+	XRay:         xray.Propagator{},
+	OpenCensus:   OpenCensusBinary{},
+}
+
+// Names returns the names of every supported format, sorted.
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the propagator of the named format. Names are
+// case-insensitive.
+func New(name string) (propagation.TextMapPropagator, error) {
+	p, ok := formats[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("propagators: unknown format %q, want one of %s", name, strings.Join(Names(), ", "))
+	}
+	return p, nil
+}
+
+// Composite returns a propagator injecting the inject formats and
+// extracting any of the extract formats. When a request carries the trace
+// context in several formats, the first of extract that holds a valid one
+// wins. Baggage is extracted by the Baggage format only. If extract is
+// empty, the inject formats are extracted.
+func Composite(inject, extract []string) (propagation.TextMapPropagator, error) {
+	if len(extract) == 0 {
+		extract = inject
+	}
+
+	var c composite
+	for _, name := range inject {
+		p, err := New(name)
+		if err != nil {
+			return nil, err
+		}
+		c.inject = append(c.inject, p)
+	}
+	for _, name := range extract {
+		p, err := New(name)
+		if err != nil {
+			return nil, err
+		}
+		c.extract = append(c.extract, p)
+	}
+	return c, nil
+}
+
+type composite struct {
+	inject  []propagation.TextMapPropagator
+	extract []propagation.TextMapPropagator
+}
+
+var _ propagation.TextMapPropagator = composite{}
+
+func (c composite) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	for _, p := range c.inject {
+		p.Inject(ctx, carrier)
+	}
+}
+
+// Extract runs the extract formats in reverse order: each format only
+// replaces the span context when it finds a valid one, so the first format
+// with a valid span context is applied last and wins.
+func (c composite) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	for i := len(c.extract) - 1; i >= 0; i-- {
+		ctx = c.extract[i].Extract(ctx, carrier)
+	}
+	return ctx
+}
+
+// Fields returns the keys Inject sets.
+func (c composite) Fields() []string {
+	var fields []string
+	seen := map[string]bool{}
+	for _, p := range c.inject {
+		for _, f := range p.Fields() {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,119 @@
+package propagators
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func remoteContext(traceID byte) context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x60, 0, 0, 0, traceID, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		SpanID:     trace.SpanID{traceID, 1, 2, 3, 4, 5, 6, 7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+}
+
+func TestRoundTripThroughHTTPHeaders(t *testing.T) {
+	want := trace.SpanContextFromContext(remoteContext(1))
+
+	for _, name := range Names() {
+		if name == Baggage {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			p, err := New(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			p.Inject(remoteContext(1), propagation.HeaderCarrier(header))
+			if len(header) == 0 {
+				t.Fatal("nothing injected")
+			}
+
+			// Callers may use any format, so extract with all of them.
+			all, err := Composite(Names(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := trace.SpanContextFromContext(all.Extract(context.Background(), propagation.HeaderCarrier(header)))
+			if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() || got.IsSampled() != want.IsSampled() {
+				t.Errorf("extracted %v from %v, want %v", got, header, want)
+			}
+		})
+	}
+}
+
+func TestCompositeInjectsOnlyInjectFormats(t *testing.T) {
+	p, err := Composite([]string{TraceContext, Jaeger}, Names())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	p.Inject(remoteContext(1), propagation.HeaderCarrier(header))
+
+	for _, key := range []string{"Traceparent", "Uber-Trace-Id"} {
+		if header.Get(key) == "" {
+			t.Errorf("%s not injected: %v", key, header)
+		}
+	}
+	for _, key := range []string{"B3", "X-B3-Traceid", "X-Amzn-Trace-Id"} {
+		if header.Get(key) != "" {
+			t.Errorf("%s injected: %v", key, header)
+		}
+	}
+}
+
+func TestCompositeFirstValidFormatWins(t *testing.T) {
+	header := http.Header{}
+	b3, _ := New(B3)
+	b3.Inject(remoteContext(1), propagation.HeaderCarrier(header))
+	tc, _ := New(TraceContext)
+	tc.Inject(remoteContext(2), propagation.HeaderCarrier(header))
+	// An invalid X-Ray header must not hide the valid ones.
+	header.Set("X-Amzn-Trace-Id", "Root=invalid")
+
+	for _, tt := range []struct {
+		extract []string
+		want    byte
+	}{
+		{[]string{XRay, TraceContext, B3}, 2},
+		{[]string{XRay, B3, TraceContext}, 1},
+	} {
+		p, err := Composite([]string{TraceContext}, tt.extract)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := trace.SpanContextFromContext(p.Extract(context.Background(), propagation.HeaderCarrier(header)))
+		if want := trace.SpanContextFromContext(remoteContext(tt.want)); got.SpanID() != want.SpanID() {
+			t.Errorf("extract %v: span %s, want %s", tt.extract, got.SpanID(), want.SpanID())
+		}
+	}
+}
+
+func TestCompositeExtractsBaggage(t *testing.T) {
+	header := http.Header{"Baggage": {"username=adam"}}
+	p, err := Composite([]string{TraceContext, Baggage}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := p.Extract(context.Background(), propagation.HeaderCarrier(header))
+	if got := baggage.Value(ctx, "username").AsString(); got != "adam" {
+		t.Errorf("baggage username = %q, want adam", got)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := Composite([]string{TraceContext}, []string{"zipkin"}); err == nil {
+		t.Error("Composite accepted an unknown format")
+	}
+	if _, err := New("B3Multi"); err != nil {
+		t.Errorf("New is case-sensitive: %v", err)
+	}
+}
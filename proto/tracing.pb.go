@@ -8,6 +8,7 @@ import (
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	descriptor "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -30,7 +31,8 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type Request struct {
-	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Id    string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Token string `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -73,6 +75,13 @@ func (m *Request) GetId() string {
 	return ""
 }
 
+func (m *Request) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
 type Response struct {
 	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 }
@@ -117,36 +126,53 @@ func (m *Response) GetId() string {
 	return ""
 }
 
+var E_Sensitive = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50000,
+	Name:          "tracing.sensitive",
+	Tag:           "varint,50000,opt,name=sensitive",
+	Filename:      "proto/tracing.proto",
+}
+
 func init() {
 	proto.RegisterType((*Request)(nil), "tracing.Request")
 	proto.RegisterType((*Response)(nil), "tracing.Response")
+	proto.RegisterExtension(E_Sensitive)
 }
 
 func init() { proto.RegisterFile("proto/tracing.proto", fileDescriptor_0497aebc504b02a6) }
 
 var fileDescriptor_0497aebc504b02a6 = []byte{
-	// 190 bytes of a gzipped FileDescriptorProto
+	// 277 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2e, 0x28, 0xca, 0x2f,
 	0xc9, 0xd7, 0x2f, 0x29, 0x4a, 0x4c, 0xce, 0xcc, 0x4b, 0xd7, 0x03, 0xf3, 0x84, 0xd8, 0xa1, 0x5c,
 	0x29, 0xdd, 0xf4, 0xcc, 0x92, 0x8c, 0xd2, 0x24, 0xbd, 0xe4, 0xfc, 0x5c, 0xfd, 0xf4, 0xfc, 0xf4,
-	0x7c, 0x7d, 0xb0, 0x7c, 0x52, 0x69, 0x1a, 0x98, 0x07, 0xd1, 0x0a, 0x62, 0x41, 0xf4, 0x29, 0x49,
-	0x72, 0xb1, 0x07, 0xa5, 0x16, 0x96, 0xa6, 0x16, 0x97, 0x08, 0xf1, 0x71, 0x31, 0x65, 0xa6, 0x48,
-	0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x31, 0x65, 0xa6, 0x28, 0x49, 0x71, 0x71, 0x04, 0xa5, 0x16,
-	0x17, 0xe4, 0xe7, 0x15, 0xa7, 0xa2, 0xcb, 0x19, 0x59, 0x71, 0xf1, 0x78, 0xa4, 0xe6, 0xe4, 0xe4,
-	0x07, 0xa7, 0x16, 0x95, 0x65, 0x26, 0xa7, 0x0a, 0x69, 0x71, 0x31, 0x07, 0x64, 0xe6, 0x09, 0x09,
-	0xe8, 0xc1, 0x5c, 0x05, 0x35, 0x54, 0x4a, 0x10, 0x49, 0x04, 0x62, 0x96, 0x93, 0xcc, 0x87, 0x87,
-	0x72, 0x8c, 0x27, 0x1e, 0xc9, 0x31, 0x5e, 0x78, 0x24, 0xc7, 0xf8, 0xe0, 0x91, 0x1c, 0xe3, 0x84,
-	0xc7, 0x72, 0x0c, 0x17, 0x1e, 0xcb, 0x31, 0xdc, 0x78, 0x2c, 0xc7, 0x90, 0xc4, 0x06, 0x76, 0x97,
-	0x31, 0x20, 0x00, 0x00, 0xff, 0xff, 0x47, 0x2c, 0x89, 0xd1, 0xe6, 0x00, 0x00, 0x00,
+	0x7c, 0x7d, 0xb0, 0x7c, 0x52, 0x69, 0x1a, 0x98, 0x07, 0xd1, 0x0a, 0x62, 0x41, 0xf4, 0x49, 0x29,
+	0xa4, 0xe7, 0xe7, 0xa7, 0xe7, 0xa4, 0x22, 0x54, 0xa5, 0xa4, 0x16, 0x27, 0x17, 0x65, 0x16, 0x94,
+	0xe4, 0x17, 0x41, 0x54, 0x28, 0x99, 0x72, 0xb1, 0x07, 0xa5, 0x16, 0x96, 0xa6, 0x16, 0x97, 0x08,
+	0xf1, 0x71, 0x31, 0x65, 0xa6, 0x48, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x31, 0x65, 0xa6, 0x08,
+	0x49, 0x71, 0xb1, 0x96, 0xe4, 0x67, 0xa7, 0xe6, 0x49, 0x30, 0x81, 0x84, 0x9c, 0x58, 0x1a, 0xb6,
+	0x4a, 0x30, 0x06, 0x41, 0x84, 0x94, 0xa4, 0xb8, 0x38, 0x82, 0x52, 0x8b, 0x0b, 0xf2, 0xf3, 0x8a,
+	0x53, 0xd1, 0xf5, 0x19, 0x59, 0x71, 0xf1, 0x78, 0xa4, 0xe6, 0xe4, 0xe4, 0x07, 0xa7, 0x16, 0x95,
+	0x65, 0x26, 0xa7, 0x0a, 0x69, 0x71, 0x31, 0x07, 0x64, 0xe6, 0x09, 0x09, 0xe8, 0xc1, 0xfc, 0x04,
+	0xb5, 0x50, 0x4a, 0x10, 0x49, 0x04, 0x62, 0x96, 0x95, 0x2d, 0x17, 0x67, 0x71, 0x6a, 0x5e, 0x71,
+	0x66, 0x49, 0x66, 0x59, 0xaa, 0x90, 0xac, 0x1e, 0xc4, 0xf9, 0x7a, 0x30, 0xe7, 0xeb, 0xb9, 0x65,
+	0xa6, 0xe6, 0xa4, 0xf8, 0x17, 0x94, 0x64, 0xe6, 0xe7, 0x15, 0x4b, 0x5c, 0x68, 0x63, 0x56, 0x60,
+	0xd4, 0xe0, 0x08, 0x42, 0xe8, 0x70, 0x92, 0xf9, 0xf0, 0x50, 0x8e, 0xf1, 0xc4, 0x23, 0x39, 0xc6,
+	0x0b, 0x8f, 0xe4, 0x18, 0x1f, 0x3c, 0x92, 0x63, 0x9c, 0xf0, 0x58, 0x8e, 0xe1, 0xc2, 0x63, 0x39,
+	0x86, 0x1b, 0x8f, 0xe5, 0x18, 0x92, 0xd8, 0xc0, 0xe6, 0x18, 0x03, 0x06, 0x00, 0xa2, 0x08, 0x8e,
+	0x3d, 0x63, 0x01, 0x00, 0x00,
 }
 
 func (this *Request) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 6)
 	s = append(s, "&tracing.Request{")
 	s = append(s, "Id: "+fmt.Sprintf("%#v", this.Id)+",\n")
+	s = append(s, "Token: "+fmt.Sprintf("%#v", this.Token)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -269,6 +295,13 @@ func (m *Request) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Token) > 0 {
+		i -= len(m.Token)
+		copy(dAtA[i:], m.Token)
+		i = encodeVarintTracing(dAtA, i, uint64(len(m.Token)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Id) > 0 {
 		i -= len(m.Id)
 		copy(dAtA[i:], m.Id)
@@ -330,6 +363,10 @@ func (m *Request) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovTracing(uint64(l))
 	}
+	l = len(m.Token)
+	if l > 0 {
+		n += 1 + l + sovTracing(uint64(l))
+	}
 	return n
 }
 
@@ -413,6 +450,38 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 			}
 			m.Id = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Token", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTracing
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTracing
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTracing
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Token = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTracing(dAtA[iNdEx:])
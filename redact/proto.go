@@ -0,0 +1,96 @@
+package redact
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+
+	tracing "tracing/proto"
+)
+
+// messageInfo lists, for one proto message type, the JSON field names that
+// carry the (tracing.sensitive) option and the message type of nested
+// message fields.
+type messageInfo struct {
+	sensitive map[string]bool
+	messages  map[string]string
+}
+
+func (m *messageInfo) isSensitive(field string) bool {
+	return m != nil && m.sensitive[field]
+}
+
+func (m *messageInfo) nested(field string) *messageInfo {
+	if m == nil {
+		return nil
+	}
+	name, ok := m.messages[field]
+	if !ok {
+		return nil
+	}
+	return infoForName(name)
+}
+
+// messageInfos caches *messageInfo by fully-qualified message name.
+var messageInfos sync.Map
+
+func infoForValue(v interface{}) *messageInfo {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil
+	}
+	return infoForName(proto.MessageName(m))
+}
+
+func infoForName(name string) *messageInfo {
+	if cached, ok := messageInfos.Load(name); ok {
+		return cached.(*messageInfo)
+	}
+
+	t := proto.MessageType(name)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil
+	}
+	msg, ok := reflect.New(t.Elem()).Interface().(descriptor.Message)
+	if !ok {
+		return nil
+	}
+
+	_, md := descriptor.ForMessage(msg)
+	info := &messageInfo{
+		sensitive: map[string]bool{},
+		messages:  map[string]string{},
+	}
+	for _, f := range md.GetField() {
+		// jsonpb may render either name depending on OrigName.
+		names := []string{f.GetName(), f.GetJsonName()}
+		if isSensitive(f) {
+			for _, n := range names {
+				info.sensitive[n] = true
+			}
+		}
+		if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			for _, n := range names {
+				info.messages[n] = strings.TrimPrefix(f.GetTypeName(), ".")
+			}
+		}
+	}
+
+	messageInfos.Store(name, info)
+	return info
+}
+
+func isSensitive(f *descriptor.FieldDescriptorProto) bool {
+	if f.Options == nil {
+		return false
+	}
+	v, err := proto.GetExtension(f.Options, tracing.E_Sensitive)
+	if err != nil {
+		return false
+	}
+	b, ok := v.(*bool)
+	return ok && *b
+}
@@ -0,0 +1,309 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Action selects how a sensitive value is rewritten.
+type Action int
+
+const (
+	// Mask replaces the value with a fixed placeholder.
+	Mask Action = iota
+	// Hash replaces the value with its SHA-256 digest, so equal values can
+	// still be correlated across spans without being readable.
+	Hash
+)
+
+const maskedValue = "[REDACTED]"
+
+func (a Action) apply(v string) string {
+	if a == Hash {
+		sum := sha256.Sum256([]byte(v))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return maskedValue
+}
+
+type fieldRule struct {
+	segments []string
+	action   Action
+}
+
+type patternRule struct {
+	re     *regexp.Regexp
+	action Action
+}
+
+// Redactor rewrites sensitive values before they become span attributes.
+// A nil *Redactor leaves every value untouched.
+type Redactor struct {
+	fields    []fieldRule
+	patterns  []patternRule
+	sensitive *Action
+}
+
+// Option configures a Redactor.
+type Option func(*Redactor)
+
+// New returns a Redactor configured by opts.
+func New(opts ...Option) *Redactor {
+	r := &Redactor{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithFields redacts the given field paths. A path is a dot separated list
+// of JSON field names ("user.email"); "*" matches any single field. A path
+// without dots matches that field at any depth. Paths are also matched,
+// case-insensitively, against HTTP header names and baggage keys.
+func WithFields(action Action, paths ...string) Option {
+	return func(r *Redactor) {
+		for _, p := range paths {
+			r.fields = append(r.fields, fieldRule{
+				segments: strings.Split(strings.ToLower(p), "."),
+				action:   action,
+			})
+		}
+	}
+}
+
+// WithPattern redacts every part of a string value matching re, wherever
+// the value appears.
+func WithPattern(action Action, re *regexp.Regexp) Option {
+	return func(r *Redactor) {
+		r.patterns = append(r.patterns, patternRule{re: re, action: action})
+	}
+}
+
+// WithSensitiveOption redacts proto message fields annotated with the
+// (tracing.sensitive) field option.
+func WithSensitiveOption(action Action) Option {
+	return func(r *Redactor) {
+		r.sensitive = &action
+	}
+}
+
+// String redacts the parts of s matched by the configured patterns.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, p := range r.patterns {
+		action := p.action
+		s = p.re.ReplaceAllStringFunc(s, action.apply)
+	}
+	return s
+}
+
+// Header redacts the value of the HTTP header name.
+func (r *Redactor) Header(name, value string) string {
+	return r.keyValue(name, value)
+}
+
+// Attributes redacts string attributes such as baggage members promoted to
+// span attributes, matching field paths against the attribute key.
+func (r *Redactor) Attributes(kvs []attribute.KeyValue) []attribute.KeyValue {
+	if r == nil {
+		return kvs
+	}
+
+	out := make([]attribute.KeyValue, len(kvs))
+	for i, kv := range kvs {
+		out[i] = kv
+		if kv.Value.Type() == attribute.STRING {
+			out[i] = attribute.String(string(kv.Key), r.keyValue(string(kv.Key), kv.Value.AsString()))
+		}
+	}
+	return out
+}
+
+func (r *Redactor) keyValue(key, value string) string {
+	if r == nil {
+		return value
+	}
+	if action, ok := r.matchField([]string{strings.ToLower(key)}); ok {
+		return action.apply(value)
+	}
+	return r.String(value)
+}
+
+// JSON redacts the JSON document payload. msg is the value payload was
+// rendered from; when it is a proto message, fields carrying the
+// (tracing.sensitive) option are redacted as well. Payloads that are not
+// valid JSON are only matched against patterns.
+//
+// The document is rewritten token by token, so fields keep their order and
+// numbers their exact text.
+func (r *Redactor) JSON(payload string, msg interface{}) string {
+	if r == nil {
+		return payload
+	}
+
+	var info *messageInfo
+	if r.sensitive != nil {
+		info = infoForValue(msg)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(payload))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := r.walk(dec, &out, nil, info); err != nil {
+		return r.String(payload)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// Trailing data after the document.
+		return r.String(payload)
+	}
+	return out.String()
+}
+
+// walk copies the next JSON value of dec to out, redacting it on the way.
+func (r *Redactor) walk(dec *json.Decoder, out *bytes.Buffer, path []string, info *messageInfo) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			out.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				if err := r.walk(dec, out, path, info); err != nil {
+					return err
+				}
+			}
+			out.WriteByte(']')
+			_, err := dec.Token()
+			return err
+		}
+
+		out.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			writeString(out, key)
+			out.WriteByte(':')
+
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key))
+			action, ok := r.matchField(childPath)
+			if !ok && info.isSensitive(key) {
+				action, ok = *r.sensitive, true
+			}
+			if ok {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return err
+				}
+				writeString(out, redactRaw(raw, action))
+				continue
+			}
+			if err := r.walk(dec, out, childPath, info.nested(key)); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+		_, err := dec.Token()
+		return err
+	case string:
+		writeString(out, r.String(tok))
+	case json.Number:
+		out.WriteString(tok.String())
+	case bool:
+		out.WriteString(strconv.FormatBool(tok))
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+func (r *Redactor) matchField(path []string) (Action, bool) {
+	for _, f := range r.fields {
+		if len(f.segments) == 1 {
+			if f.segments[0] == path[len(path)-1] || f.segments[0] == "*" {
+				return f.action, true
+			}
+			continue
+		}
+		if len(f.segments) != len(path) {
+			continue
+		}
+		matched := true
+		for i, s := range f.segments {
+			if s != "*" && s != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return f.action, true
+		}
+	}
+	return 0, false
+}
+
+// redactRaw rewrites a whole JSON value. Objects and arrays are replaced
+// by their compact JSON encoding, redacted, so no nested field leaks.
+func redactRaw(raw json.RawMessage, action Action) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return action.apply(str)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return action.apply(string(raw))
+	}
+	return action.apply(compact.String())
+}
+
+// writeString writes s to out as a JSON string, leaving <, > and & as they
+// are.
+func writeString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	// Encode terminates the value with a newline.
+	out.Truncate(out.Len() - 1)
+}
+
+var (
+	globalMu       sync.RWMutex
+	globalRedactor *Redactor
+)
+
+// Global returns the Redactor registered with SetGlobal, or nil if none is.
+func Global() *Redactor {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalRedactor
+}
+
+// SetGlobal registers r as the Redactor used by instrumentation that is not
+// given one explicitly.
+func SetGlobal(r *Redactor) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalRedactor = r
+}
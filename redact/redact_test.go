@@ -0,0 +1,194 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	tracing "tracing/proto"
+)
+
+func TestJSON(t *testing.T) {
+	email := regexp.MustCompile(`[a-z]+@[a-z.]+`)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		payload string
+		want    string
+	}{
+		{
+			name:    "field at any depth",
+			opts:    []Option{WithFields(Mask, "password")},
+			payload: `{"password":"a","user":{"password":"b","name":"adam"}}`,
+			want:    `{"password":"[REDACTED]","user":{"password":"[REDACTED]","name":"adam"}}`,
+		},
+		{
+			name:    "keys match case-insensitively",
+			opts:    []Option{WithFields(Mask, "Password")},
+			payload: `{"PASSWORD":"a","passWord":"b"}`,
+			want:    `{"PASSWORD":"[REDACTED]","passWord":"[REDACTED]"}`,
+		},
+		{
+			name:    "dotted path",
+			opts:    []Option{WithFields(Mask, "user.email")},
+			payload: `{"email":"a@b.c","user":{"email":"d@e.f"}}`,
+			want:    `{"email":"a@b.c","user":{"email":"[REDACTED]"}}`,
+		},
+		{
+			name:    "wildcard segment",
+			opts:    []Option{WithFields(Mask, "*.token")},
+			payload: `{"token":"a","auth":{"token":"b"},"other":{"token":"c","deeper":{"token":"d"}}}`,
+			want:    `{"token":"a","auth":{"token":"[REDACTED]"},"other":{"token":"[REDACTED]","deeper":{"token":"d"}}}`,
+		},
+		{
+			name:    "objects and arrays are redacted whole",
+			opts:    []Option{WithFields(Mask, "card", "pins")},
+			payload: `{"card":{"number":"4111","cvc":123},"pins":[1, 2]}`,
+			want:    `{"card":"[REDACTED]","pins":"[REDACTED]"}`,
+		},
+		{
+			name:    "fields inside arrays",
+			opts:    []Option{WithFields(Mask, "users.secret")},
+			payload: `{"users":[{"id":1,"secret":"a"},{"id":2,"secret":"b"}]}`,
+			want:    `{"users":[{"id":1,"secret":"[REDACTED]"},{"id":2,"secret":"[REDACTED]"}]}`,
+		},
+		{
+			name:    "hash",
+			opts:    []Option{WithFields(Hash, "id")},
+			payload: `{"id":"123Adam"}`,
+			want:    `{"id":"` + sha("123Adam") + `"}`,
+		},
+		{
+			name:    "hash of a non-string uses its compact encoding",
+			opts:    []Option{WithFields(Hash, "ids")},
+			payload: `{"ids": [1, 2]}`,
+			want:    `{"ids":"` + sha("[1,2]") + `"}`,
+		},
+		{
+			name:    "patterns in every string",
+			opts:    []Option{WithPattern(Mask, email)},
+			payload: `{"note":"mail adam@example.com","list":["eve@example.com"]}`,
+			want:    `{"note":"mail [REDACTED]","list":["[REDACTED]"]}`,
+		},
+		{
+			name:    "order, numbers and HTML are kept",
+			opts:    []Option{WithFields(Mask, "secret")},
+			payload: `{"z":9007199254740993,"a":1.50,"html":"<b>&</b>","secret":"x","n":null,"t":true}`,
+			want:    `{"z":9007199254740993,"a":1.50,"html":"<b>&</b>","secret":"[REDACTED]","n":null,"t":true}`,
+		},
+		{
+			name:    "invalid JSON is only matched against patterns",
+			opts:    []Option{WithFields(Mask, "password"), WithPattern(Mask, email)},
+			payload: `password=hunter2 adam@example.com`,
+			want:    `password=hunter2 [REDACTED]`,
+		},
+		{
+			name:    "trailing data is invalid",
+			opts:    []Option{WithFields(Mask, "password")},
+			payload: `{"password":"a"} {}`,
+			want:    `{"password":"a"} {}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.opts...).JSON(tt.payload, nil); got != tt.want {
+				t.Errorf("JSON(%s) = %s, want %s", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	r := New(
+		WithFields(Mask, "authorization"),
+		WithFields(Hash, "x-user-id"),
+		WithPattern(Mask, regexp.MustCompile(`token=\w+`)),
+	)
+
+	tests := []struct {
+		name, value, want string
+	}{
+		{"Authorization", "Bearer abc", maskedValue},
+		{"AUTHORIZATION", "Bearer abc", maskedValue},
+		{"X-User-Id", "42", sha("42")},
+		{"Referer", "https://example.com/?token=abc", "https://example.com/?[REDACTED]"},
+		{"Accept", "text/html", "text/html"},
+	}
+	for _, tt := range tests {
+		if got := r.Header(tt.name, tt.value); got != tt.want {
+			t.Errorf("Header(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+
+	var nilRedactor *Redactor
+	if got := nilRedactor.Header("Authorization", "Bearer abc"); got != "Bearer abc" {
+		t.Errorf("nil Redactor Header = %q, want the value untouched", got)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	r := New(WithFields(Mask, "password"), WithPattern(Mask, regexp.MustCompile(`\d{16}`)))
+
+	got := r.Attributes([]attribute.KeyValue{
+		attribute.String("password", "hunter2"),
+		attribute.String("note", "card 4111111111111111"),
+		attribute.String("username", "donuts"),
+		attribute.Int("password", 1234),
+	})
+	want := []attribute.KeyValue{
+		attribute.String("password", maskedValue),
+		attribute.String("note", "card "+maskedValue),
+		attribute.String("username", "donuts"),
+		// Only strings are redacted.
+		attribute.Int("password", 1234),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes = %v, want %v", got, want)
+	}
+}
+
+func TestSensitiveOption(t *testing.T) {
+	req := &tracing.Request{Id: "123Adam", Token: "hunter2"}
+	payload := `{"id":"123Adam","token":"hunter2"}`
+
+	tests := []struct {
+		name string
+		r    *Redactor
+		msg  interface{}
+		want string
+	}{
+		{"mask", New(WithSensitiveOption(Mask)), req, `{"id":"123Adam","token":"[REDACTED]"}`},
+		{"hash", New(WithSensitiveOption(Hash)), req, `{"id":"123Adam","token":"` + sha("hunter2") + `"}`},
+		{"option not enabled", New(), req, payload},
+		{"not a proto message", New(WithSensitiveOption(Mask)), nil, payload},
+		{"message without sensitive fields", New(WithSensitiveOption(Mask)), &tracing.Response{}, payload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.JSON(payload, tt.msg); got != tt.want {
+				t.Errorf("JSON(%s) = %s, want %s", payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobal(t *testing.T) {
+	prev := Global()
+	t.Cleanup(func() { SetGlobal(prev) })
+
+	r := New()
+	SetGlobal(r)
+	if Global() != r {
+		t.Error("Global does not return the Redactor set with SetGlobal")
+	}
+}
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,433 @@
+package spantest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of spantest.MatchGolden with the recorded traces")
+
+// Placeholders replacing the values that change from run to run.
+const (
+	// TimestampPlaceholder replaces start, end and event times.
+	TimestampPlaceholder = "<timestamp>"
+	// MaskedPlaceholder replaces the values of attributes masked with
+	// WithMaskedAttributes.
+	MaskedPlaceholder = "<masked>"
+)
+
+// Node is a span of a normalized trace tree, as stored in golden files.
+// Trace and span IDs are replaced by placeholders numbered in tree order,
+// "trace-1", "span-1", and so are attribute values equal to them, such as
+// the legacy TraceID attribute of grpctrace.
+type Node struct {
+	Name            string                 `json:"name"`
+	Kind            string                 `json:"kind"`
+	TraceID         string                 `json:"trace_id"`
+	SpanID          string                 `json:"span_id"`
+	Parent          string                 `json:"parent,omitempty"`
+	RemoteParent    bool                   `json:"remote_parent,omitempty"`
+	Instrumentation string                 `json:"instrumentation,omitempty"`
+	StartTime       string                 `json:"start_time"`
+	EndTime         string                 `json:"end_time"`
+	Status          Status                 `json:"status"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty"`
+	Events          []Event                `json:"events,omitempty"`
+	Links           []string               `json:"links,omitempty"`
+	Children        []*Node                `json:"children,omitempty"`
+}
+
+// Status is the status of a Node.
+type Status struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+// Event is an event of a Node.
+type Event struct {
+	Name       string                 `json:"name"`
+	Time       string                 `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// SnapshotOption configures how spans are normalized.
+type SnapshotOption func(*snapshotConfig)
+
+type snapshotConfig struct {
+	masked  map[attribute.Key]bool
+	ignored map[attribute.Key]bool
+}
+
+// WithMaskedAttributes replaces the values of the attributes keys, of
+// spans and events, by MaskedPlaceholder. Use it for values that change
+// from run to run, such as durations and stack traces.
+func WithMaskedAttributes(keys ...attribute.Key) SnapshotOption {
+	return func(cfg *snapshotConfig) {
+		for _, k := range keys {
+			cfg.masked[k] = true
+		}
+	}
+}
+
+// WithoutAttributes leaves the attributes keys out of snapshots.
+func WithoutAttributes(keys ...attribute.Key) SnapshotOption {
+	return func(cfg *snapshotConfig) {
+		for _, k := range keys {
+			cfg.ignored[k] = true
+		}
+	}
+}
+
+// Snapshot returns the trace trees spans form, normalized so that the same
+// scenario always gives the same trees: IDs and timestamps are replaced by
+// placeholders, and roots and siblings are ordered by name, kind and start
+// time. Spans whose parent is not among spans are roots.
+func Snapshot(spans []*tracesdk.SpanSnapshot, opts ...SnapshotOption) []*Node {
+	cfg := snapshotConfig{masked: map[attribute.Key]bool{}, ignored: map[attribute.Key]bool{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	byID := make(map[trace.SpanID]*tracesdk.SpanSnapshot, len(spans))
+	for _, s := range spans {
+		byID[s.SpanContext.SpanID()] = s
+	}
+	var roots []*tracesdk.SpanSnapshot
+	children := map[trace.SpanID][]*tracesdk.SpanSnapshot{}
+	for _, s := range spans {
+		if _, ok := byID[s.Parent.SpanID()]; ok && s.Parent.IsValid() {
+			children[s.Parent.SpanID()] = append(children[s.Parent.SpanID()], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	// IDs are numbered first, so attributes and links can refer to any
+	// span of the trees.
+	ids := newPlaceholders()
+	var number func(s *tracesdk.SpanSnapshot)
+	number = func(s *tracesdk.SpanSnapshot) {
+		ids.trace(s.SpanContext.TraceID())
+		ids.span(s.SpanContext.SpanID())
+		sortSpans(children[s.SpanContext.SpanID()])
+		for _, c := range children[s.SpanContext.SpanID()] {
+			number(c)
+		}
+	}
+	sortSpans(roots)
+	for _, s := range roots {
+		number(s)
+	}
+	// Parents outside spans are numbered last.
+	for _, s := range roots {
+		if s.Parent.IsValid() {
+			ids.trace(s.Parent.TraceID())
+			ids.span(s.Parent.SpanID())
+		}
+	}
+
+	var build func(s *tracesdk.SpanSnapshot) *Node
+	build = func(s *tracesdk.SpanSnapshot) *Node {
+		n := &Node{
+			Name:            s.Name,
+			Kind:            s.SpanKind.String(),
+			TraceID:         ids.values[s.SpanContext.TraceID().String()],
+			SpanID:          ids.values[s.SpanContext.SpanID().String()],
+			RemoteParent:    s.Parent.IsRemote(),
+			Instrumentation: s.InstrumentationLibrary.Name,
+			StartTime:       TimestampPlaceholder,
+			EndTime:         TimestampPlaceholder,
+			Status:          Status{Code: s.StatusCode.String(), Description: s.StatusMessage},
+			Attributes:      cfg.attributes(s.Attributes, ids),
+		}
+		for _, e := range s.MessageEvents {
+			n.Events = append(n.Events, Event{
+				Name:       e.Name,
+				Time:       TimestampPlaceholder,
+				Attributes: cfg.attributes(e.Attributes, ids),
+			})
+		}
+		for _, l := range s.Links {
+			n.Links = append(n.Links, ids.span(l.SpanContext.SpanID()))
+		}
+		for _, c := range children[s.SpanContext.SpanID()] {
+			n.Children = append(n.Children, build(c))
+		}
+		return n
+	}
+	nodes := make([]*Node, 0, len(roots))
+	for _, s := range roots {
+		n := build(s)
+		if s.Parent.IsValid() {
+			n.Parent = ids.values[s.Parent.SpanID().String()]
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// MarshalSnapshot returns the JSON encoding golden files hold for nodes.
+func MarshalSnapshot(nodes []*Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(nodes); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MatchGolden asserts that the trace trees spans form match the golden
+// file path. Run the test with -update to write the file instead. On a
+// mismatch, the failure shows both trees as text, lines of the golden one
+// prefixed with "-" and lines of the recorded one with "+".
+func MatchGolden(t testing.TB, path string, spans []*tracesdk.SpanSnapshot, opts ...SnapshotOption) {
+	t.Helper()
+
+	got, err := MarshalSnapshot(Snapshot(spans, opts...))
+	if err != nil {
+		t.Fatalf("spantest: marshal snapshot: %v", err)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("spantest: %v", err)
+		}
+		if err := ioutil.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("spantest: %v", err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("spantest: golden file %s does not exist, run the test with -update to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("spantest: %v", err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	wantTree, err := renderJSON(want)
+	if err != nil {
+		t.Fatalf("spantest: golden file %s: %v", path, err)
+	}
+	gotTree, err := renderJSON(got)
+	if err != nil {
+		t.Fatalf("spantest: %v", err)
+	}
+	if wantTree == gotTree {
+		t.Errorf("trace snapshot differs from %s in formatting only, run the test with -update to rewrite it", path)
+		return
+	}
+	t.Errorf("trace snapshot differs from %s (-golden +recorded), run the test with -update to accept it:\n%s",
+		path, diffLines(strings.Split(wantTree, "\n"), strings.Split(gotTree, "\n")))
+}
+
+// MatchGolden asserts that the recorded traces match the golden file path,
+// see MatchGolden.
+func (r *Recorder) MatchGolden(path string, opts ...SnapshotOption) {
+	r.t.Helper()
+	MatchGolden(r.t, path, r.Spans(), opts...)
+}
+
+func (cfg *snapshotConfig) attributes(kvs []attribute.KeyValue, ids *placeholders) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		switch {
+		case cfg.ignored[kv.Key]:
+		case cfg.masked[kv.Key]:
+			attrs[string(kv.Key)] = MaskedPlaceholder
+		case kv.Value.Type() == attribute.STRING:
+			v := kv.Value.AsString()
+			if id, ok := ids.values[v]; ok {
+				v = id
+			}
+			attrs[string(kv.Key)] = v
+		default:
+			attrs[string(kv.Key)] = kv.Value.AsInterface()
+		}
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return attrs
+}
+
+// placeholders numbers trace and span IDs in the order they are first
+// seen.
+type placeholders struct {
+	values        map[string]string
+	traceN, spanN int
+}
+
+func newPlaceholders() *placeholders {
+	return &placeholders{values: map[string]string{}}
+}
+
+func (p *placeholders) trace(id trace.TraceID) string {
+	if v, ok := p.values[id.String()]; ok {
+		return v
+	}
+	p.traceN++
+	v := "trace-" + strconv.Itoa(p.traceN)
+	p.values[id.String()] = v
+	return v
+}
+
+func (p *placeholders) span(id trace.SpanID) string {
+	if v, ok := p.values[id.String()]; ok {
+		return v
+	}
+	p.spanN++
+	v := "span-" + strconv.Itoa(p.spanN)
+	p.values[id.String()] = v
+	return v
+}
+
+func sortSpans(spans []*tracesdk.SpanSnapshot) {
+	sort.SliceStable(spans, func(i, j int) bool {
+		a, b := spans[i], spans[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.SpanKind != b.SpanKind {
+			return a.SpanKind < b.SpanKind
+		}
+		return a.StartTime.Before(b.StartTime)
+	})
+}
+
+// renderJSON renders the trees of a golden file as indented text.
+// Numbers keep the text they have in the file, so both sides of a diff
+// render alike.
+func renderJSON(b []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var nodes []*Node
+	if err := dec.Decode(&nodes); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, n := range nodes {
+		render(&sb, n, 0)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+func render(sb *strings.Builder, n *Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(sb, "%s%s [%s] %s/%s", indent, n.Name, n.Kind, n.TraceID, n.SpanID)
+	if n.Parent != "" {
+		fmt.Fprintf(sb, " parent=%s", n.Parent)
+	}
+	if n.RemoteParent {
+		sb.WriteString(" remote")
+	}
+	sb.WriteString("\n")
+
+	if n.Instrumentation != "" {
+		fmt.Fprintf(sb, "%s  instrumentation: %s\n", indent, n.Instrumentation)
+	}
+	fmt.Fprintf(sb, "%s  status: %s", indent, n.Status.Code)
+	if n.Status.Description != "" {
+		fmt.Fprintf(sb, " %q", n.Status.Description)
+	}
+	sb.WriteString("\n")
+	for _, k := range sortedKeys(n.Attributes) {
+		fmt.Fprintf(sb, "%s  %s = %s\n", indent, k, formatValue(n.Attributes[k]))
+	}
+	for _, e := range n.Events {
+		fmt.Fprintf(sb, "%s  event %s\n", indent, e.Name)
+		for _, k := range sortedKeys(e.Attributes) {
+			fmt.Fprintf(sb, "%s    %s = %s\n", indent, k, formatValue(e.Attributes[k]))
+		}
+	}
+	for _, l := range n.Links {
+		fmt.Fprintf(sb, "%s  link %s\n", indent, l)
+	}
+	for _, c := range n.Children {
+		render(sb, c, depth+1)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		return strconv.Quote(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// diffLines returns want and got, line by line, with the lines only in
+// want prefixed by "-", those only in got by "+" and common ones by " ".
+func diffLines(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// want[i:] and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			sb.WriteString("  " + want[i] + "\n")
+			i++
+			j++
+		case j == len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + want[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + got[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,100 @@
+package spantest
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scenario records a server span with two children, the second named
+// child, and returns the recorder.
+func scenario(t testing.TB, child string) *Recorder {
+	rec := New(t)
+	tracer := rec.TracerProvider().Tracer("spantest")
+
+	ctx, server := tracer.Start(context.Background(), "server", trace.WithSpanKind(trace.SpanKindServer))
+	_, b := tracer.Start(ctx, child, trace.WithAttributes(
+		attribute.String("trace", server.SpanContext().TraceID().String()),
+		attribute.Int64("elapsed_ms", int64(len(t.Name()))),
+	))
+	b.AddEvent("done", trace.WithAttributes(attribute.Bool("ok", true)))
+	b.End()
+	_, a := tracer.Start(ctx, "a", trace.WithLinks(trace.Link{SpanContext: b.SpanContext()}))
+	a.SetStatus(codes.Error, "failed")
+	a.End()
+	server.End()
+	return rec
+}
+
+func TestSnapshot(t *testing.T) {
+	opts := []SnapshotOption{WithMaskedAttributes("elapsed_ms")}
+	first, err := MarshalSnapshot(Snapshot(scenario(t, "b").Spans(), opts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := MarshalSnapshot(Snapshot(scenario(t, "b").Spans(), opts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Fatalf("snapshots of the same scenario differ:\n%s\n%s", first, second)
+	}
+
+	nodes := Snapshot(scenario(t, "b").Spans(), opts...)
+	if len(nodes) != 1 || len(nodes[0].Children) != 2 {
+		t.Fatalf("Snapshot = %s, want one root with two children", first)
+	}
+	server, a, b := nodes[0], nodes[0].Children[0], nodes[0].Children[1]
+	if server.SpanID != "span-1" || a.Name != "a" || a.SpanID != "span-2" || b.SpanID != "span-3" {
+		t.Errorf("spans are %s %s, %s %s, %s %s, want server, a and b numbered in order",
+			server.Name, server.SpanID, a.Name, a.SpanID, b.Name, b.SpanID)
+	}
+	if b.Attributes["trace"] != "trace-1" || b.Attributes["elapsed_ms"] != MaskedPlaceholder {
+		t.Errorf("b attributes = %v, want the trace ID replaced and elapsed_ms masked", b.Attributes)
+	}
+	if len(a.Links) != 1 || a.Links[0] != "span-3" {
+		t.Errorf("a links = %v, want span-3", a.Links)
+	}
+	if b.StartTime != TimestampPlaceholder || b.Events[0].Time != TimestampPlaceholder {
+		t.Errorf("b times = %s, %s, want placeholders", b.StartTime, b.Events[0].Time)
+	}
+
+	if got := Snapshot(scenario(t, "b").Spans(), WithoutAttributes("trace", "elapsed_ms"))[0].Children[1].Attributes; got != nil {
+		t.Errorf("attributes left out = %v, want none", got)
+	}
+}
+
+func TestMatchGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "scenario.golden.json")
+	opts := []SnapshotOption{WithMaskedAttributes("elapsed_ms")}
+
+	*update = true
+	scenario(t, "b").MatchGolden(path, opts...)
+	*update = false
+
+	rt := &recordingT{TB: t}
+	scenario(rt, "b").MatchGolden(path, opts...)
+	if len(rt.errors) != 0 {
+		t.Fatalf("the scenario does not match its own golden file: %s", rt.errors)
+	}
+
+	scenario(rt, "renamed").MatchGolden(path, opts...)
+	if len(rt.errors) != 1 {
+		t.Fatalf("a renamed span reported %d errors, want 1", len(rt.errors))
+	}
+	for _, want := range []string{
+		"  server [server] trace-1/span-1\n",
+		"-     b [internal] trace-1/span-3\n",
+		"+     renamed [internal] trace-1/span-3\n",
+		"      trace = \"trace-1\"\n",
+	} {
+		if !strings.Contains(rt.errors[0], want) {
+			t.Errorf("diff lacks %q:\n%s", want, rt.errors[0])
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package spantest records the spans instrumentation creates in memory and
+// asserts on them in tests:
+//
+//	rec := spantest.New(t)
+//	handler := iristrace.Middleware(iristrace.WithTracerProvider(rec.TracerProvider()))
+//	...
+//	server := rec.SpanOfKind("GET /ping", trace.SpanKindServer).
+//		HasAttribute(semconv.HTTPStatusCodeKey.Int(200)).
+//		HasStatus(codes.Unset)
+//	rec.SpanOfKind("tracing.HelloService/Pin", trace.SpanKindClient).HasParent(server)
+//
+// Assertions report failures with t.Errorf and keep going, so one test
+// lists every difference at once.
+//
+// MatchGolden compares whole trace trees with a golden file instead,
+// normalized so that IDs and timestamps do not change from run to run:
+//
+//	rec.MatchGolden("testdata/ping.golden.json")
+//
+// Run the tests with -update to write the golden files.
+package spantest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Recorder keeps the spans of a TracerProvider in memory as soon as they
+// end.
+type Recorder struct {
+	t        testing.TB
+	exporter *tracetest.InMemoryExporter
+	provider *tracesdk.TracerProvider
+}
+
+// New returns a Recorder whose TracerProvider samples every span and is
+// shut down when the test ends. opts configure the provider further, e.g.
+// with a resource.
+func New(t testing.TB, opts ...tracesdk.TracerProviderOption) *Recorder {
+	exporter := tracetest.NewInMemoryExporter()
+	opts = append([]tracesdk.TracerProviderOption{tracesdk.WithSampler(tracesdk.AlwaysSample())}, opts...)
+	opts = append(opts, tracesdk.WithSyncer(exporter))
+
+	r := &Recorder{
+		t:        t,
+		exporter: exporter,
+		provider: tracesdk.NewTracerProvider(opts...),
+	}
+	t.Cleanup(func() {
+		_ = r.provider.Shutdown(context.Background())
+	})
+	return r
+}
+
+// TracerProvider returns the provider whose spans are recorded.
+func (r *Recorder) TracerProvider() *tracesdk.TracerProvider {
+	return r.provider
+}
+
+// Exporter returns the exporter holding the recorded spans.
+func (r *Recorder) Exporter() *tracetest.InMemoryExporter {
+	return r.exporter
+}
+
+// Spans returns the ended spans, in the order they ended.
+func (r *Recorder) Spans() []*tracesdk.SpanSnapshot {
+	return r.exporter.GetSpans()
+}
+
+// Reset forgets the spans recorded so far.
+func (r *Recorder) Reset() {
+	r.exporter.Reset()
+}
+
+// Len asserts that n spans ended.
+func (r *Recorder) Len(n int) {
+	r.t.Helper()
+	if spans := r.Spans(); len(spans) != n {
+		r.t.Errorf("recorded %d spans, want %d: %s", len(spans), n, names(spans))
+	}
+}
+
+// Span asserts that a span named name ended and returns the first one for
+// further assertions.
+func (r *Recorder) Span(name string) *SpanAssert {
+	r.t.Helper()
+	return r.find(name, func(*tracesdk.SpanSnapshot) bool { return true }, "")
+}
+
+// SpanOfKind asserts that a span of kind named name ended and returns the
+// first one for further assertions. Use it when clients and servers name
+// their spans alike, as gRPC ones do.
+func (r *Recorder) SpanOfKind(name string, kind trace.SpanKind) *SpanAssert {
+	r.t.Helper()
+	return r.find(name, func(s *tracesdk.SpanSnapshot) bool { return s.SpanKind == kind }, " of kind "+kind.String())
+}
+
+func (r *Recorder) find(name string, match func(*tracesdk.SpanSnapshot) bool, desc string) *SpanAssert {
+	r.t.Helper()
+	spans := r.Spans()
+	for _, s := range spans {
+		if s.Name == name && match(s) {
+			return &SpanAssert{t: r.t, span: s}
+		}
+	}
+	r.t.Errorf("no span %q%s, recorded %s", name, desc, names(spans))
+	return &SpanAssert{t: r.t}
+}
+
+// SpanAssert asserts on a recorded span. Every method returns the receiver
+// so assertions chain; on a span that was not found, they do nothing, the
+// missing span having been reported already.
+type SpanAssert struct {
+	t    testing.TB
+	span *tracesdk.SpanSnapshot
+}
+
+// Snapshot returns the span, or nil if it was not found.
+func (a *SpanAssert) Snapshot() *tracesdk.SpanSnapshot {
+	return a.span
+}
+
+// HasKind asserts that the span is of kind.
+func (a *SpanAssert) HasKind(kind trace.SpanKind) *SpanAssert {
+	a.t.Helper()
+	if a.span != nil && a.span.SpanKind != kind {
+		a.errorf("kind is %s, want %s", a.span.SpanKind, kind)
+	}
+	return a
+}
+
+// HasParent asserts that the span is a child of parent, in the same
+// process or across one.
+func (a *SpanAssert) HasParent(parent *SpanAssert) *SpanAssert {
+	a.t.Helper()
+	if a.span == nil || parent.span == nil {
+		return a
+	}
+	want := parent.span.SpanContext
+	if a.span.Parent.TraceID() != want.TraceID() || a.span.Parent.SpanID() != want.SpanID() {
+		a.errorf("parent is %s/%s, want span %q %s/%s",
+			a.span.Parent.TraceID(), a.span.Parent.SpanID(), parent.span.Name, want.TraceID(), want.SpanID())
+	}
+	return a
+}
+
+// IsRoot asserts that the span has no parent, local or remote.
+func (a *SpanAssert) IsRoot() *SpanAssert {
+	a.t.Helper()
+	if a.span != nil && a.span.Parent.IsValid() {
+		a.errorf("has parent %s/%s, want a root span", a.span.Parent.TraceID(), a.span.Parent.SpanID())
+	}
+	return a
+}
+
+// HasRemoteParent asserts that the parent of the span was propagated by
+// another process.
+func (a *SpanAssert) HasRemoteParent() *SpanAssert {
+	a.t.Helper()
+	if a.span != nil && !(a.span.Parent.IsValid() && a.span.Parent.IsRemote()) {
+		a.errorf("parent %s/%s is not remote", a.span.Parent.TraceID(), a.span.Parent.SpanID())
+	}
+	return a
+}
+
+// HasAttribute asserts that the span has the attribute kv.
+func (a *SpanAssert) HasAttribute(kv attribute.KeyValue) *SpanAssert {
+	a.t.Helper()
+	if a.span == nil {
+		return a
+	}
+	v, ok := lookup(a.span.Attributes, kv.Key)
+	switch {
+	case !ok:
+		a.errorf("has no attribute %s, want %s", kv.Key, kv.Value.Emit())
+	case v != kv.Value:
+		a.errorf("attribute %s is %s, want %s", kv.Key, v.Emit(), kv.Value.Emit())
+	}
+	return a
+}
+
+// HasAttributeKey asserts that the span has an attribute key, whatever its
+// value.
+func (a *SpanAssert) HasAttributeKey(key attribute.Key) *SpanAssert {
+	a.t.Helper()
+	if a.span == nil {
+		return a
+	}
+	if _, ok := lookup(a.span.Attributes, key); !ok {
+		a.errorf("has no attribute %s", key)
+	}
+	return a
+}
+
+// HasNoAttribute asserts that the span has no attribute key.
+func (a *SpanAssert) HasNoAttribute(key attribute.Key) *SpanAssert {
+	a.t.Helper()
+	if a.span == nil {
+		return a
+	}
+	if v, ok := lookup(a.span.Attributes, key); ok {
+		a.errorf("has attribute %s=%s, want none", key, v.Emit())
+	}
+	return a
+}
+
+// HasStatus asserts that the status code of the span is code.
+func (a *SpanAssert) HasStatus(code codes.Code) *SpanAssert {
+	a.t.Helper()
+	if a.span != nil && a.span.StatusCode != code {
+		a.errorf("status is %s %q, want %s", a.span.StatusCode, a.span.StatusMessage, code)
+	}
+	return a
+}
+
+// HasStatusMessage asserts that the status description of the span is msg.
+func (a *SpanAssert) HasStatusMessage(msg string) *SpanAssert {
+	a.t.Helper()
+	if a.span != nil && a.span.StatusMessage != msg {
+		a.errorf("status description is %q, want %q", a.span.StatusMessage, msg)
+	}
+	return a
+}
+
+// HasEvent asserts that the span has an event named name with at least
+// the attributes attrs.
+func (a *SpanAssert) HasEvent(name string, attrs ...attribute.KeyValue) *SpanAssert {
+	a.t.Helper()
+	if a.span == nil {
+		return a
+	}
+	var found []string
+	for _, e := range a.span.MessageEvents {
+		if e.Name != name {
+			continue
+		}
+		if hasAll(e.Attributes, attrs) {
+			return a
+		}
+		found = append(found, formatAttrs(e.Attributes))
+	}
+	if len(found) == 0 {
+		a.errorf("has no event %q", name)
+	} else {
+		a.errorf("has no event %q with %s, found %q events with %s", name, formatAttrs(attrs), name, strings.Join(found, "; "))
+	}
+	return a
+}
+
+// HasNoEvent asserts that the span has no event named name.
+func (a *SpanAssert) HasNoEvent(name string) *SpanAssert {
+	a.t.Helper()
+	if a.span == nil {
+		return a
+	}
+	for _, e := range a.span.MessageEvents {
+		if e.Name == name {
+			a.errorf("has event %q with %s, want none", name, formatAttrs(e.Attributes))
+			break
+		}
+	}
+	return a
+}
+
+func (a *SpanAssert) errorf(format string, args ...interface{}) {
+	a.t.Helper()
+	a.t.Errorf("span %q (%s): %s", a.span.Name, a.span.SpanKind, fmt.Sprintf(format, args...))
+}
+
+func lookup(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func hasAll(attrs, want []attribute.KeyValue) bool {
+	for _, kv := range want {
+		if v, ok := lookup(attrs, kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func formatAttrs(attrs []attribute.KeyValue) string {
+	parts := make([]string, len(attrs))
+	for i, kv := range attrs {
+		parts[i] = string(kv.Key) + "=" + kv.Value.Emit()
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func names(spans []*tracesdk.SpanSnapshot) string {
+	parts := make([]string, len(spans))
+	for i, s := range spans {
+		parts[i] = fmt.Sprintf("%q (%s)", s.Name, s.SpanKind)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,93 @@
+package spantest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingT records the failures assertions report instead of failing
+// the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	rt := &recordingT{TB: t}
+	rec := New(rt)
+	tracer := rec.TracerProvider().Tracer("spantest")
+
+	ctx, parent := tracer.Start(context.Background(), "parent", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "child",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("key", "value")),
+	)
+	child.AddEvent("message", trace.WithAttributes(attribute.Int("id", 1)))
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	parent.End()
+
+	rec.Len(2)
+	p := rec.Span("parent").HasKind(trace.SpanKindServer).IsRoot()
+	rec.SpanOfKind("child", trace.SpanKindClient).
+		HasParent(p).
+		HasAttribute(attribute.String("key", "value")).
+		HasAttributeKey("key").
+		HasNoAttribute("other").
+		HasStatus(codes.Error).
+		HasStatusMessage("boom").
+		HasEvent("message", attribute.Int("id", 1)).
+		HasNoEvent("exception")
+	if len(rt.errors) != 0 {
+		t.Fatalf("passing assertions reported %q", rt.errors)
+	}
+
+	rec.Len(3)
+	rec.Span("missing").HasKind(trace.SpanKindServer)
+	rec.SpanOfKind("child", trace.SpanKindServer)
+	c := rec.Span("child").
+		HasKind(trace.SpanKindServer).
+		IsRoot().
+		HasRemoteParent().
+		HasAttribute(attribute.String("key", "other")).
+		HasAttribute(attribute.String("missing", "value")).
+		HasStatus(codes.Ok).
+		HasEvent("message", attribute.Int("id", 2)).
+		HasEvent("exception")
+	p.HasParent(c)
+
+	want := []string{
+		"recorded 2 spans, want 3",
+		`no span "missing"`,
+		`no span "child" of kind server`,
+		"kind is client, want server",
+		"want a root span",
+		"is not remote",
+		"attribute key is value, want other",
+		"has no attribute missing",
+		"status is Error",
+		`has no event "message" with {id=2}, found "message" events with {id=1}`,
+		`has no event "exception"`,
+		`parent is 00000000000000000000000000000000/0000000000000000, want span "child"`,
+	}
+	if len(rt.errors) != len(want) {
+		t.Fatalf("failing assertions reported %d errors, want %d:\n%s", len(rt.errors), len(want), strings.Join(rt.errors, "\n"))
+	}
+	for i, w := range want {
+		if !strings.Contains(rt.errors[i], w) {
+			t.Errorf("error %d = %q, want it to contain %q", i, rt.errors[i], w)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tracelog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Logger writes structured log records as JSON lines, each with the time,
+// level, message, the trace fields of the context and key-value fields:
+//
+//	{"time":"...","level":"info","msg":"pin","trace_id":"...","span_id":"...","sampled":true,"id":"123"}
+type Logger struct {
+	cfg config
+
+	mu *sync.Mutex
+	w  io.Writer
+
+	level  Level
+	fields []interface{}
+	now    func() time.Time
+}
+
+// New returns a Logger writing records of LevelInfo and above to w.
+func New(w io.Writer, opts ...Option) *Logger {
+	return &Logger{
+		cfg:   newConfig(opts...),
+		mu:    &sync.Mutex{},
+		w:     w,
+		level: LevelInfo,
+		now:   time.Now,
+	}
+}
+
+// With returns a Logger adding the alternating keys and values kv to every
+// record.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	c := *l
+	c.fields = append(append([]interface{}(nil), l.fields...), kv...)
+	return &c
+}
+
+// WithLevel returns a Logger dropping records below level.
+func (l *Logger) WithLevel(level Level) *Logger {
+	c := *l
+	c.level = level
+	return &c
+}
+
+// Debug logs msg at LevelDebug.
+func (l *Logger) Debug(ctx context.Context, msg string, kv ...interface{}) {
+	l.Log(ctx, LevelDebug, msg, kv...)
+}
+
+// Info logs msg at LevelInfo.
+func (l *Logger) Info(ctx context.Context, msg string, kv ...interface{}) {
+	l.Log(ctx, LevelInfo, msg, kv...)
+}
+
+// Warn logs msg at LevelWarn.
+func (l *Logger) Warn(ctx context.Context, msg string, kv ...interface{}) {
+	l.Log(ctx, LevelWarn, msg, kv...)
+}
+
+// Error logs msg at LevelError.
+func (l *Logger) Error(ctx context.Context, msg string, kv ...interface{}) {
+	l.Log(ctx, LevelError, msg, kv...)
+}
+
+// Log writes a record of level with msg, the trace fields of ctx and the
+// alternating keys and values kv.
+func (l *Logger) Log(ctx context.Context, level Level, msg string, kv ...interface{}) {
+	if level < l.level {
+		return
+	}
+
+	fields := append(append([]interface{}(nil), l.fields...), kv...)
+	if l.cfg.SpanEvents {
+		addSpanEvent(ctx, level, msg, fields)
+	}
+
+	line := []byte("{")
+	line = appendField(line, "time", l.now().UTC().Format(time.RFC3339Nano))
+	line = appendField(line, "level", level.String())
+	line = appendField(line, "msg", msg)
+	all := append(TraceFields(ctx), fields...)
+	for i := 0; i < len(all); i += 2 {
+		line = appendField(line, keyOf(all[i]), valueAt(all, i+1))
+	}
+	line = append(line, "}\n"...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(line)
+}
+
+// appendField appends "key":value to the JSON object being built in line,
+// in the order fields are logged.
+func appendField(line []byte, key string, value interface{}) []byte {
+	if len(line) > 1 {
+		line = append(line, ',')
+	}
+	k, _ := json.Marshal(key)
+	line = append(line, k...)
+	line = append(line, ':')
+
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(err.Error())
+	}
+	return append(line, v...)
+}
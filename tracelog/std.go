@@ -0,0 +1,65 @@
+package tracelog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// StdLogger adds the trace fields of a context to the lines of a standard
+// library *log.Logger, e.g.
+//
+//	2021/05/01 12:00:00 pin 123 trace_id=4bf9... span_id=00f0... sampled=true
+type StdLogger struct {
+	cfg config
+	l   *log.Logger
+}
+
+// NewStdLogger returns a StdLogger writing to l, or to a logger like the
+// one of the log package functions if l is nil.
+func NewStdLogger(l *log.Logger, opts ...Option) *StdLogger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &StdLogger{cfg: newConfig(opts...), l: l}
+}
+
+// Printf logs like log.Printf, adding the trace fields of ctx.
+func (l *StdLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	l.output(ctx, fmt.Sprintf(format, v...))
+}
+
+// Println logs like log.Println, adding the trace fields of ctx.
+func (l *StdLogger) Println(ctx context.Context, v ...interface{}) {
+	l.output(ctx, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+// Fatalf is equivalent to Printf followed by os.Exit(1).
+func (l *StdLogger) Fatalf(ctx context.Context, format string, v ...interface{}) {
+	l.output(ctx, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// Logger returns the underlying *log.Logger.
+func (l *StdLogger) Logger() *log.Logger {
+	return l.l
+}
+
+func (l *StdLogger) output(ctx context.Context, msg string) {
+	msg = strings.TrimSuffix(msg, "\n")
+	if l.cfg.SpanEvents {
+		addSpanEvent(ctx, LevelInfo, msg, nil)
+	}
+
+	fields := TraceFields(ctx)
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %s=%v", fields[i], fields[i+1])
+	}
+	// Skip output and Printf, Println or Fatalf so log.Lshortfile names
+	// the caller.
+	_ = l.l.Output(3, b.String())
+}
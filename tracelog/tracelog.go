@@ -0,0 +1,128 @@
+// Package tracelog correlates log records with traces. Records written
+// with the context of a traced request carry its trace_id, span_id and
+// sampled flag, and may be mirrored as events on its span.
+package tracelog
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the trace fields added to log records.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+	SampledKey = "sampled"
+)
+
+// logEventName is the name of the span events mirroring log records.
+const logEventName = "log"
+
+// Attribute keys of the span events mirroring log records.
+var (
+	severityKey = attribute.Key("log.severity")
+	messageKey  = attribute.Key("log.message")
+)
+
+// Level is the severity of a log record.
+type Level int
+
+// Levels of log records, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+type config struct {
+	SpanEvents bool
+}
+
+func newConfig(opts ...Option) config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Option specifies logger configuration options.
+type Option func(*config)
+
+// WithSpanEvents also records every log record written with the context of
+// a recording span as a "log" event on that span.
+func WithSpanEvents() Option {
+	return func(cfg *config) {
+		cfg.SpanEvents = true
+	}
+}
+
+// TraceFields returns the trace_id, span_id and sampled flag of the span in
+// ctx as alternating keys and values, or nil if ctx holds no valid span.
+func TraceFields(ctx context.Context) []interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []interface{}{
+		TraceIDKey, sc.TraceID().String(),
+		SpanIDKey, sc.SpanID().String(),
+		SampledKey, sc.IsSampled(),
+	}
+}
+
+// addSpanEvent mirrors a log record on the span of ctx, if it records.
+func addSpanEvent(ctx context.Context, level Level, msg string, kv []interface{}) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		severityKey.String(level.String()),
+		messageKey.String(msg),
+	}
+	for i := 0; i < len(kv); i += 2 {
+		v := valueAt(kv, i+1)
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		attrs = append(attrs, attribute.Any(keyOf(kv[i]), v))
+	}
+	span.AddEvent(logEventName, trace.WithAttributes(attrs...))
+}
+
+func keyOf(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+// valueAt returns kv[i], or a marker when an odd number of key-value
+// arguments left the last key without a value.
+func valueAt(kv []interface{}, i int) interface{} {
+	if i < len(kv) {
+		return kv[i]
+	}
+	return "!MISSING"
+}
@@ -0,0 +1,254 @@
+package tracelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"tracing/spantest"
+)
+
+var testTime = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestLogger(opts ...Option) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(&buf, opts...)
+	l.now = func() time.Time { return testTime }
+	return l, &buf
+}
+
+func TestLoggerJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		kv   []interface{}
+		want string
+	}{
+		{
+			name: "message only",
+			msg:  "pin",
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"pin"}`,
+		},
+		{
+			name: "fields in order",
+			msg:  "pin",
+			kv:   []interface{}{"id", "123", "n", 2, "ok", true},
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"pin","id":"123","n":2,"ok":true}`,
+		},
+		{
+			name: "escaping",
+			msg:  "say \"hi\"\n\t<b>",
+			kv:   []interface{}{"quote\"key", `back\slash`},
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"say \"hi\"\n\t\u003cb\u003e","quote\"key":"back\\slash"}`,
+		},
+		{
+			name: "error value",
+			msg:  "failed",
+			kv:   []interface{}{"error", errors.New("boom")},
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"failed","error":"boom"}`,
+		},
+		{
+			name: "missing value",
+			msg:  "odd",
+			kv:   []interface{}{"id"},
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"odd","id":"!MISSING"}`,
+		},
+		{
+			name: "non-string key",
+			msg:  "odd",
+			kv:   []interface{}{1, "one"},
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"odd","1":"one"}`,
+		},
+		{
+			name: "unmarshalable value",
+			msg:  "odd",
+			kv:   []interface{}{"ch", make(chan int)},
+			want: `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"odd","ch":"json: unsupported type: chan int"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, buf := newTestLogger()
+			l.Info(context.Background(), tt.msg, tt.kv...)
+
+			got := buf.String()
+			if got != tt.want+"\n" {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("invalid JSON: %s", got)
+			}
+		})
+	}
+}
+
+func TestLoggerWith(t *testing.T) {
+	l, buf := newTestLogger()
+	base := l.With("service", "server")
+	base.With("id", "1").Info(context.Background(), "a")
+	base.Info(context.Background(), "b", "n", 2)
+
+	want := `{"time":"2021-05-01T12:00:00Z","level":"info","msg":"a","service":"server","id":"1"}
+{"time":"2021-05-01T12:00:00Z","level":"info","msg":"b","service":"server","n":2}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestLoggerTraceFields(t *testing.T) {
+	rec := spantest.New(t)
+	ctx, span := rec.TracerProvider().Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+	sc := span.SpanContext()
+
+	l, buf := newTestLogger()
+	l.Info(ctx, "pin", "id", "123")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"time":     "2021-05-01T12:00:00Z",
+		"level":    "info",
+		"msg":      "pin",
+		TraceIDKey: sc.TraceID().String(),
+		SpanIDKey:  sc.SpanID().String(),
+		SampledKey: true,
+		"id":       "123",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	// Trace fields come before the logged fields.
+	if i, j := strings.Index(buf.String(), TraceIDKey), strings.Index(buf.String(), `"id"`); i > j {
+		t.Errorf("trace fields after logged fields: %s", buf.String())
+	}
+}
+
+func TestTraceFields(t *testing.T) {
+	if f := TraceFields(context.Background()); f != nil {
+		t.Errorf("TraceFields without span = %v, want nil", f)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9},
+		SpanID:  trace.SpanID{0x00, 0xf0},
+	})
+	got := TraceFields(trace.ContextWithSpanContext(context.Background(), sc))
+	want := []interface{}{
+		TraceIDKey, "4bf90000000000000000000000000000",
+		SpanIDKey, "00f0000000000000",
+		SampledKey, false,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("TraceFields = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TraceFields = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  []string
+	}{
+		{LevelDebug, []string{"debug", "info", "warn", "error"}},
+		{LevelInfo, []string{"info", "warn", "error"}},
+		{LevelWarn, []string{"warn", "error"}},
+		{LevelError, []string{"error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			l, buf := newTestLogger()
+			l = l.WithLevel(tt.level)
+
+			ctx := context.Background()
+			l.Debug(ctx, "m")
+			l.Info(ctx, "m")
+			l.Warn(ctx, "m")
+			l.Error(ctx, "m")
+
+			var got []string
+			dec := json.NewDecoder(buf)
+			for dec.More() {
+				var r struct{ Level string }
+				if err := dec.Decode(&r); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, r.Level)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("levels logged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerSpanEvents(t *testing.T) {
+	rec := spantest.New(t)
+	tracer := rec.TracerProvider().Tracer("test")
+
+	ctx, span := tracer.Start(context.Background(), "op")
+	l, _ := newTestLogger(WithSpanEvents())
+	l = l.WithLevel(LevelInfo).With("service", "server")
+	l.Warn(ctx, "slow", "ms", 250, "error", errors.New("boom"))
+	l.Debug(ctx, "filtered")
+	span.End()
+
+	rec.Span("op").
+		HasEvent(logEventName,
+			severityKey.String("warn"),
+			messageKey.String("slow"),
+			attribute.String("service", "server"),
+			attribute.Int("ms", 250),
+			attribute.String("error", "boom"),
+		)
+	if n := len(rec.Span("op").Snapshot().MessageEvents); n != 1 {
+		t.Errorf("span has %d events, want only the record above the level", n)
+	}
+
+	// Without the option, nothing is mirrored.
+	rec.Reset()
+	ctx, span = tracer.Start(context.Background(), "plain")
+	l, _ = newTestLogger()
+	l.Info(ctx, "pin")
+	span.End()
+	rec.Span("plain").HasNoEvent(logEventName)
+}
+
+func TestStdLogger(t *testing.T) {
+	rec := spantest.New(t)
+	ctx, span := rec.TracerProvider().Tracer("test").Start(context.Background(), "op")
+	sc := span.SpanContext()
+
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), WithSpanEvents())
+	l.Printf(ctx, "pin %s\n", "123")
+	l.Println(context.Background(), "no", "span")
+	span.End()
+
+	want := "pin 123 trace_id=" + sc.TraceID().String() + " span_id=" + sc.SpanID().String() + " sampled=true\nno span\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+	rec.Span("op").HasEvent(logEventName, severityKey.String("info"), messageKey.String("pin 123"))
+}
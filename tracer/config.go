@@ -0,0 +1,486 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"tracing/propagators"
+)
+
+// Environment variables read by ConfigFromEnv. They follow the OpenTelemetry
+// SDK environment variable specification.
+const (
+	envServiceName        = "OTEL_SERVICE_NAME"
+	envResourceAttributes = "OTEL_RESOURCE_ATTRIBUTES"
+	envTracesExporter     = "OTEL_TRACES_EXPORTER"
+	envTracesSampler      = "OTEL_TRACES_SAMPLER"
+	envTracesSamplerArg   = "OTEL_TRACES_SAMPLER_ARG"
+	envPropagators        = "OTEL_PROPAGATORS"
+)
+
+// envSamplerRateLimit caps the traces sampled per second. OpenTelemetry
+// has no variable for it.
+const envSamplerRateLimit = "TRACER_SAMPLER_RATE_LIMIT"
+
+// envExtractPropagators lists the propagation formats accepted from
+// callers, if they differ from OTEL_PROPAGATORS.
+const envExtractPropagators = "TRACER_EXTRACT_PROPAGATORS"
+
+// envMetricsAddr sets the address NewMetrics serves the Prometheus scrape
+// endpoint on.
+const envMetricsAddr = "TRACER_METRICS_ADDR"
+
+// ConfigFileEnv names the environment variable the binaries read the path
+// of their configuration file from.
+const ConfigFileEnv = "TRACER_CONFIG"
+
+// Resource attribute keys that OTEL_RESOURCE_ATTRIBUTES may use to set the
+// service version and environment.
+const (
+	resourceServiceName    = "service.name"
+	resourceServiceVersion = "service.version"
+	resourceEnvironment    = "deployment.environment"
+)
+
+// Sampler names accepted by Config.Sampler.
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// Config describes how TracerProviders built by New identify the service,
+// sample and export spans.
+type Config struct {
+	ServiceName    string `json:"service_name" yaml:"service_name"`
+	ServiceVersion string `json:"service_version" yaml:"service_version"`
+	Environment    string `json:"environment" yaml:"environment"`
+
+	// Exporter is one of the Exporter* names. Endpoint defaults to the
+	// usual local address of the selected exporter's collector or agent.
+	Exporter string `json:"exporter" yaml:"exporter"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Protocol is one of the Protocol* names, used by the OTLP exporter.
+	Protocol string `json:"protocol" yaml:"protocol"`
+
+	// Headers are sent with every export request.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+	// Compression is CompressionGzip or empty; only OTLP supports it.
+	Compression string    `json:"compression" yaml:"compression"`
+	Timeout     Duration  `json:"timeout" yaml:"timeout"`
+	TLS         TLSConfig `json:"tls" yaml:"tls"`
+
+	// Sampler is one of the Sampler* names. SamplerArg is the sampling ratio
+	// of the ratio based samplers, in [0, 1].
+	Sampler    string  `json:"sampler" yaml:"sampler"`
+	SamplerArg float64 `json:"sampler_arg" yaml:"sampler_arg"`
+	// RateLimit caps the root spans the sampler samples per second; zero
+	// means no limit. Spans with a parent are not limited.
+	RateLimit float64 `json:"rate_limit" yaml:"rate_limit"`
+	// SamplingRules override the sampler for the root spans they match.
+	SamplingRules []SamplingRule `json:"sampling_rules" yaml:"sampling_rules"`
+	// SampleHealthChecks disables the HealthCheckRules that otherwise keep
+	// health checks out of every trace.
+	SampleHealthChecks bool `json:"sample_health_checks" yaml:"sample_health_checks"`
+	// TailSampling buffers sampled spans and keeps only interesting traces.
+	TailSampling TailSamplingConfig `json:"tail_sampling" yaml:"tail_sampling"`
+
+	ResourceAttributes map[string]string `json:"resource_attributes" yaml:"resource_attributes"`
+
+	// Propagators are the names of the formats, see package propagators,
+	// trace context and baggage are sent to callees in.
+	Propagators []string `json:"propagators" yaml:"propagators"`
+	// ExtractPropagators are the formats accepted from callers; the first
+	// one holding a valid trace context wins. Empty means Propagators.
+	ExtractPropagators []string `json:"extract_propagators" yaml:"extract_propagators"`
+
+	// MetricsAddr is the host:port NewMetrics serves the Prometheus scrape
+	// endpoint on, at /metrics. Empty disables the endpoint.
+	MetricsAddr string `json:"metrics_addr" yaml:"metrics_addr"`
+
+	// ShutdownTimeout bounds how long the ShutdownFunc returned by New
+	// waits for queued spans to be exported; zero means no deadline.
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+
+	// sources maps a setting key to where its value was read from, so
+	// validation errors can name the environment variable or file.
+	sources map[string]string
+}
+
+// ConfigError reports a single invalid setting. Setting names the
+// environment variable or configuration file key the value came from.
+type ConfigError struct {
+	Setting string
+	Value   string
+	Reason  string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("tracer: invalid %s %q: %s", e.Setting, e.Value, e.Reason)
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden.
+func DefaultConfig() Config {
+	return Config{
+		ServiceName: "demo-service-adam",
+		Environment: "production",
+		Exporter:    ExporterJaeger,
+		Protocol:    ProtocolGRPC,
+		Timeout:     Duration(10 * time.Second),
+		Sampler:     SamplerParentBasedAlwaysOn,
+		SamplerArg:  1,
+		Propagators: []string{propagators.TraceContext, propagators.Baggage},
+		TailSampling: TailSamplingConfig{
+			DecisionWait:     defaultDecisionWait,
+			KeepErrors:       true,
+			MaxTraces:        defaultMaxTraces,
+			MaxSpansPerTrace: defaultMaxSpansPerTrace,
+		},
+
+		ShutdownTimeout: Duration(5 * time.Second),
+	}
+}
+
+// LoadConfig returns DefaultConfig overridden by the YAML or JSON file at
+// path, if path is not empty, and then by the OTEL_* environment variables.
+// The result is validated.
+func LoadConfig(path string) (Config, error) {
+	return DefaultConfig().Load(path)
+}
+
+// Load returns c overridden by the YAML or JSON file at path, if path is not
+// empty, and then by the OTEL_* environment variables. Binaries with their
+// own defaults use it in place of LoadConfig. The result is validated.
+func (c Config) Load(path string) (Config, error) {
+	cfg := c
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.readEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// ConfigFromEnv returns DefaultConfig overridden by the OTEL_* environment
+// variables. The result is validated.
+func ConfigFromEnv() (Config, error) {
+	return LoadConfig("")
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("tracer: read config: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("tracer: config file %s: unsupported extension %q, want .yaml, .yml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("tracer: config file %s: %w", path, err)
+	}
+
+	// Decode again, loosely, only to learn which settings the file set.
+	var keys map[string]interface{}
+	if err := yaml.Unmarshal(data, &keys); err == nil {
+		for k := range keys {
+			c.setSource(k, fmt.Sprintf("%s in %s", k, path))
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	c.sources[key] = source
+}
+
+// setting returns the name to report for key in a *ConfigError.
+func (c Config) setting(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return key
+}
+
+func (c *Config) readEnv(lookup func(string) (string, bool)) error {
+	if v, ok := lookup(envResourceAttributes); ok && v != "" {
+		attrs, err := parseResourceAttributes(v)
+		if err != nil {
+			return err
+		}
+		for k, v := range attrs {
+			switch k {
+			case resourceServiceName:
+				c.ServiceName = v
+				c.setSource("service_name", envResourceAttributes)
+			case resourceServiceVersion:
+				c.ServiceVersion = v
+				c.setSource("service_version", envResourceAttributes)
+			case resourceEnvironment:
+				c.Environment = v
+				c.setSource("environment", envResourceAttributes)
+			default:
+				if c.ResourceAttributes == nil {
+					c.ResourceAttributes = map[string]string{}
+				}
+				c.ResourceAttributes[k] = v
+				c.setSource("resource_attributes", envResourceAttributes)
+			}
+		}
+	}
+
+	// OTEL_SERVICE_NAME takes precedence over service.name in
+	// OTEL_RESOURCE_ATTRIBUTES.
+	if v, ok := lookup(envServiceName); ok && v != "" {
+		c.ServiceName = v
+		c.setSource("service_name", envServiceName)
+	}
+	if v, ok := lookup(envTracesExporter); ok && v != "" {
+		c.Exporter = strings.ToLower(v)
+		c.setSource("exporter", envTracesExporter)
+	}
+	if err := c.readExporterEnv(lookup); err != nil {
+		return err
+	}
+	if v, ok := lookup(envTracesSampler); ok && v != "" {
+		c.Sampler = strings.ToLower(v)
+		c.setSource("sampler", envTracesSampler)
+	}
+	if v, ok := lookup(envTracesSamplerArg); ok && v != "" {
+		arg, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return &ConfigError{Setting: envTracesSamplerArg, Value: v, Reason: "not a number"}
+		}
+		c.SamplerArg = arg
+		c.setSource("sampler_arg", envTracesSamplerArg)
+	}
+	if v, ok := lookup(envSamplerRateLimit); ok && v != "" {
+		limit, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return &ConfigError{Setting: envSamplerRateLimit, Value: v, Reason: "not a number"}
+		}
+		c.RateLimit = limit
+		c.setSource("rate_limit", envSamplerRateLimit)
+	}
+	if v, ok := lookup(envPropagators); ok && v != "" {
+		c.Propagators = splitList(v)
+		c.setSource("propagators", envPropagators)
+	}
+	if v, ok := lookup(envExtractPropagators); ok && v != "" {
+		c.ExtractPropagators = splitList(v)
+		c.setSource("extract_propagators", envExtractPropagators)
+	}
+	if v, ok := lookup(envMetricsAddr); ok {
+		c.MetricsAddr = v
+		c.setSource("metrics_addr", envMetricsAddr)
+	}
+
+	return nil
+}
+
+// splitList splits the comma separated list s, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseResourceAttributes parses the key1=value1,key2=value2 format of
+// OTEL_RESOURCE_ATTRIBUTES. Values may be percent-encoded.
+func parseResourceAttributes(s string) (map[string]string, error) {
+	return parseKeyValues(envResourceAttributes, s)
+}
+
+// parseKeyValues parses the key1=value1,key2=value2 format shared by
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_EXPORTER_OTLP_HEADERS.
+func parseKeyValues(setting, s string) (map[string]string, error) {
+	attrs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, &ConfigError{Setting: setting, Value: pair, Reason: "want key=value"}
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, &ConfigError{Setting: setting, Value: pair, Reason: "value is not properly percent-encoded"}
+		}
+		attrs[strings.TrimSpace(kv[0])] = v
+	}
+	return attrs, nil
+}
+
+// Validate reports the first invalid setting of c as a *ConfigError.
+func (c Config) Validate() error {
+	if strings.TrimSpace(c.ServiceName) == "" {
+		return &ConfigError{Setting: c.setting("service_name"), Value: c.ServiceName, Reason: "must not be empty"}
+	}
+
+	if err := c.validateExporter(); err != nil {
+		return err
+	}
+
+	switch c.Sampler {
+	case SamplerAlwaysOn, SamplerAlwaysOff, SamplerParentBasedAlwaysOn, SamplerParentBasedAlwaysOff:
+	case SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+		if c.SamplerArg < 0 || c.SamplerArg > 1 {
+			return &ConfigError{Setting: c.setting("sampler_arg"), Value: formatFloat(c.SamplerArg), Reason: "ratio must be between 0 and 1"}
+		}
+	default:
+		return &ConfigError{
+			Setting: c.setting("sampler"),
+			Value:   c.Sampler,
+			Reason: fmt.Sprintf("want one of %s", strings.Join([]string{
+				SamplerAlwaysOn, SamplerAlwaysOff, SamplerTraceIDRatio,
+				SamplerParentBasedAlwaysOn, SamplerParentBasedAlwaysOff, SamplerParentBasedTraceIDRatio,
+			}, ", ")),
+		}
+	}
+
+	if c.RateLimit < 0 {
+		return &ConfigError{Setting: c.setting("rate_limit"), Value: formatFloat(c.RateLimit), Reason: "must not be negative"}
+	}
+	if err := c.validateSamplingRules(); err != nil {
+		return err
+	}
+	if err := c.validateTailSampling(); err != nil {
+		return err
+	}
+
+	if len(c.Propagators) == 0 {
+		return &ConfigError{Setting: c.setting("propagators"), Value: "", Reason: "must name at least one format"}
+	}
+	for _, setting := range []struct {
+		key   string
+		names []string
+	}{{"propagators", c.Propagators}, {"extract_propagators", c.ExtractPropagators}} {
+		for _, name := range setting.names {
+			if _, err := propagators.New(name); err != nil {
+				return &ConfigError{Setting: c.setting(setting.key), Value: name, Reason: fmt.Sprintf("want one of %s", strings.Join(propagators.Names(), ", "))}
+			}
+		}
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return &ConfigError{Setting: c.setting("metrics_addr"), Value: c.MetricsAddr, Reason: "want host:port"}
+		}
+	}
+
+	if c.ShutdownTimeout < 0 {
+		return &ConfigError{Setting: c.setting("shutdown_timeout"), Value: time.Duration(c.ShutdownTimeout).String(), Reason: "must not be negative"}
+	}
+
+	for k := range c.ResourceAttributes {
+		if strings.TrimSpace(k) == "" {
+			return &ConfigError{Setting: c.setting("resource_attributes"), Value: k, Reason: "key must not be empty"}
+		}
+	}
+
+	return nil
+}
+
+func (c Config) validateSamplingRules() error {
+	for i, r := range c.SamplingRules {
+		if r.Method != "" && !strings.HasPrefix(r.Method, "/") {
+			return &ConfigError{Setting: c.ruleSetting(i, "method"), Value: r.Method, Reason: "want a full method like /package.Service/Method"}
+		}
+		if r.Ratio < 0 || r.Ratio > 1 {
+			return &ConfigError{Setting: c.ruleSetting(i, "ratio"), Value: formatFloat(r.Ratio), Reason: "ratio must be between 0 and 1"}
+		}
+		if r.RateLimit < 0 {
+			return &ConfigError{Setting: c.ruleSetting(i, "rate_limit"), Value: formatFloat(r.RateLimit), Reason: "must not be negative"}
+		}
+		if r.RateLimit > 0 && r.Ratio == 0 {
+			// The limit would never apply: a ratio of zero samples nothing.
+			return &ConfigError{Setting: c.ruleSetting(i, "ratio"), Value: formatFloat(r.Ratio), Reason: "a rule with a rate limit needs a ratio above 0, e.g. 1"}
+		}
+	}
+	return nil
+}
+
+func (c Config) validateTailSampling() error {
+	t := c.TailSampling
+	if !t.Enabled {
+		return nil
+	}
+	if t.DecisionWait <= 0 {
+		return &ConfigError{Setting: c.nestedSetting("tail_sampling", "tail_sampling.decision_wait"), Value: time.Duration(t.DecisionWait).String(), Reason: "must be positive"}
+	}
+	if t.Latency < 0 {
+		return &ConfigError{Setting: c.nestedSetting("tail_sampling", "tail_sampling.latency"), Value: time.Duration(t.Latency).String(), Reason: "must not be negative"}
+	}
+	if t.Ratio < 0 || t.Ratio > 1 {
+		return &ConfigError{Setting: c.nestedSetting("tail_sampling", "tail_sampling.ratio"), Value: formatFloat(t.Ratio), Reason: "ratio must be between 0 and 1"}
+	}
+	if t.MaxTraces <= 0 {
+		return &ConfigError{Setting: c.nestedSetting("tail_sampling", "tail_sampling.max_traces"), Value: strconv.Itoa(t.MaxTraces), Reason: "must be positive"}
+	}
+	if t.MaxSpansPerTrace <= 0 {
+		return &ConfigError{Setting: c.nestedSetting("tail_sampling", "tail_sampling.max_spans_per_trace"), Value: strconv.Itoa(t.MaxSpansPerTrace), Reason: "must be positive"}
+	}
+	for i, p := range t.Attributes {
+		if strings.TrimSpace(p.Key) == "" {
+			return &ConfigError{Setting: c.nestedSetting("tail_sampling", fmt.Sprintf("tail_sampling.attributes[%d].key", i)), Value: p.Key, Reason: "must not be empty"}
+		}
+	}
+	return nil
+}
+
+// ruleSetting names field of the i-th sampling rule.
+func (c Config) ruleSetting(i int, field string) string {
+	return c.nestedSetting("sampling_rules", fmt.Sprintf("sampling_rules[%d].%s", i, field))
+}
+
+// nestedSetting names a setting nested under key, e.g.
+// "sampling_rules[2].ratio in tracer.yaml".
+func (c Config) nestedSetting(key, nested string) string {
+	return strings.Replace(c.setting(key), key, nested, 1)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
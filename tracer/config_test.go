@@ -0,0 +1,296 @@
+package tracer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPropagatorsFromEnv(t *testing.T) {
+	env := map[string]string{
+		envPropagators:        "tracecontext, baggage,b3",
+		envExtractPropagators: "tracecontext,jaeger,xray,zipkin",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.readEnv(lookup); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tracecontext", "baggage", "b3"}; !reflect.DeepEqual(cfg.Propagators, want) {
+		t.Errorf("Propagators = %v, want %v", cfg.Propagators, want)
+	}
+
+	err := cfg.Validate()
+	ce, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("Validate() = %v, want *ConfigError", err)
+	}
+	if ce.Setting != envExtractPropagators || ce.Value != "zipkin" {
+		t.Errorf("ConfigError = %+v, want zipkin in %s", ce, envExtractPropagators)
+	}
+}
+
+// setEnv replaces the OTEL_* and TRACER_* environment variables with env
+// until the test ends.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	saved := map[string]string{}
+	for _, kv := range os.Environ() {
+		k := kv[:strings.IndexByte(kv, '=')]
+		if strings.HasPrefix(k, "OTEL_") || strings.HasPrefix(k, "TRACER_") {
+			saved[k] = os.Getenv(k)
+			os.Unsetenv(k)
+		}
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+		for k, v := range saved {
+			os.Setenv(k, v)
+		}
+	})
+}
+
+// writeConfig writes a configuration file named name holding data and
+// returns its path.
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	setEnv(t, nil)
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DefaultConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("LoadConfig(\"\") = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	files := map[string]string{
+		"tracer.yaml": `
+service_name: from-file
+service_version: "1.2"
+exporter: otlp
+endpoint: http://collector:4317
+sampler: parentbased_traceidratio
+sampler_arg: 0.5
+rate_limit: 10
+shutdown_timeout: 2s
+`,
+		"tracer.json": `{
+	"service_name": "from-file",
+	"service_version": "1.2",
+	"exporter": "otlp",
+	"endpoint": "http://collector:4317",
+	"sampler": "parentbased_traceidratio",
+	"sampler_arg": 0.5,
+	"rate_limit": 10,
+	"shutdown_timeout": "2s"
+}`,
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfig(t, name, data)
+			setEnv(t, map[string]string{
+				envServiceName:        "from-env",
+				envTracesSamplerArg:   "0.25",
+				envResourceAttributes: "service.name=ignored,team=tracing",
+				envOTLPInsecure:       "true",
+			})
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The environment wins over the file, the file over the
+			// defaults.
+			for _, c := range []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"ServiceName", cfg.ServiceName, "from-env"},
+				{"SamplerArg", cfg.SamplerArg, 0.25},
+				{"ServiceVersion", cfg.ServiceVersion, "1.2"},
+				{"Exporter", cfg.Exporter, ExporterOTLP},
+				{"Endpoint", cfg.Endpoint, "http://collector:4317"},
+				{"Sampler", cfg.Sampler, SamplerParentBasedTraceIDRatio},
+				{"RateLimit", cfg.RateLimit, 10.0},
+				{"ShutdownTimeout", cfg.ShutdownTimeout, Duration(2 * time.Second)},
+				{"TLS.Insecure", cfg.TLS.Insecure, true},
+				{"ResourceAttributes", cfg.ResourceAttributes, map[string]string{"team": "tracing"}},
+				{"Environment", cfg.Environment, "production"},
+				{"Protocol", cfg.Protocol, ProtocolGRPC},
+				{"Propagators", cfg.Propagators, DefaultConfig().Propagators},
+			} {
+				if !reflect.DeepEqual(c.got, c.want) {
+					t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+				}
+			}
+
+			for key, want := range map[string]string{
+				"service_name":    envServiceName,
+				"sampler_arg":     envTracesSamplerArg,
+				"tls.insecure":    envOTLPInsecure,
+				"service_version": "service_version in " + path,
+				"environment":     "environment",
+			} {
+				if got := cfg.setting(key); got != want {
+					t.Errorf("setting(%q) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		setting string
+		value   string
+	}{
+		{
+			name:    "sampler from env",
+			env:     map[string]string{envTracesSampler: "sometimes"},
+			setting: envTracesSampler,
+			value:   "sometimes",
+		},
+		{
+			name:    "sampler arg not a number",
+			env:     map[string]string{envTracesSamplerArg: "half"},
+			setting: envTracesSamplerArg,
+			value:   "half",
+		},
+		{
+			name:    "sampler arg from file",
+			file:    "sampler: traceidratio\nsampler_arg: 2\n",
+			setting: "sampler_arg in {file}",
+			value:   "2",
+		},
+		{
+			name:    "sampler arg from env over file",
+			file:    "sampler: traceidratio\nsampler_arg: 0.5\n",
+			env:     map[string]string{envTracesSamplerArg: "1.5"},
+			setting: envTracesSamplerArg,
+			value:   "1.5",
+		},
+		{
+			name:    "rate limit",
+			env:     map[string]string{envSamplerRateLimit: "-1"},
+			setting: envSamplerRateLimit,
+			value:   "-1",
+		},
+		{
+			name:    "sampling rule",
+			file:    "sampling_rules:\n  - route: /ping\n  - route: /pong\n    ratio: 2\n",
+			setting: "sampling_rules[1].ratio in {file}",
+			value:   "2",
+		},
+		{
+			name:    "sampling rule rate limit without ratio",
+			file:    "sampling_rules:\n  - route: /ping\n    rate_limit: 10\n",
+			setting: "sampling_rules[0].ratio in {file}",
+			value:   "0",
+		},
+		{
+			name:    "resource attributes",
+			env:     map[string]string{envResourceAttributes: "team"},
+			setting: envResourceAttributes,
+			value:   "team",
+		},
+		{
+			name:    "empty service name",
+			file:    "service_name: \" \"\n",
+			setting: "service_name in {file}",
+			value:   " ",
+		},
+		{
+			name:    "otlp insecure",
+			env:     map[string]string{envTracesExporter: "otlp", envOTLPInsecure: "maybe"},
+			setting: envOTLPInsecure,
+			value:   "maybe",
+		},
+		{
+			name:    "otlp timeout",
+			env:     map[string]string{envTracesExporter: "otlp", envOTLPTimeout: "10s"},
+			setting: envOTLPTimeout,
+			value:   "10s",
+		},
+		{
+			name:    "metrics address",
+			env:     map[string]string{envMetricsAddr: "9464"},
+			setting: envMetricsAddr,
+			value:   "9464",
+		},
+		{
+			name:    "shutdown timeout",
+			file:    "shutdown_timeout: -1s\n",
+			setting: "shutdown_timeout in {file}",
+			value:   "-1s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = writeConfig(t, "tracer.yaml", tt.file)
+			}
+			setEnv(t, tt.env)
+
+			_, err := LoadConfig(path)
+			ce, ok := err.(*ConfigError)
+			if !ok {
+				t.Fatalf("LoadConfig() = %v, want *ConfigError", err)
+			}
+			setting := strings.Replace(tt.setting, "{file}", path, 1)
+			if ce.Setting != setting || ce.Value != tt.value {
+				t.Errorf("ConfigError = %+v, want %q in %s", ce, tt.value, setting)
+			}
+			if !strings.Contains(ce.Error(), setting) {
+				t.Errorf("Error() = %q, does not name %s", ce.Error(), setting)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	setEnv(t, nil)
+
+	for name, data := range map[string]string{
+		"unknown.yaml": "service_nam: typo\n",
+		"unknown.json": `{"service_nam": "typo"}`,
+		"bad.yaml":     "timeout: soon\n",
+		"tracer.toml":  "service_name = \"x\"\n",
+	} {
+		path := writeConfig(t, name, data)
+		if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("LoadConfig(%s) = %v, want an error naming the file", name, err)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadConfig of a missing file succeeded")
+	}
+}
@@ -0,0 +1,450 @@
+package tracer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/exporters/trace/jaeger"
+	"go.opentelemetry.io/otel/exporters/trace/zipkin"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables configuring the exporters, read after
+// OTEL_TRACES_EXPORTER selected one.
+const (
+	envJaegerEndpoint     = "OTEL_EXPORTER_JAEGER_ENDPOINT"
+	envJaegerAgentHost    = "OTEL_EXPORTER_JAEGER_AGENT_HOST"
+	envJaegerAgentPort    = "OTEL_EXPORTER_JAEGER_AGENT_PORT"
+	envZipkinEndpoint     = "OTEL_EXPORTER_ZIPKIN_ENDPOINT"
+	envOTLPEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envOTLPTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	envOTLPProtocol       = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	envOTLPHeaders        = "OTEL_EXPORTER_OTLP_HEADERS"
+	envOTLPCompression    = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	envOTLPTimeout        = "OTEL_EXPORTER_OTLP_TIMEOUT"
+	envOTLPCertificate    = "OTEL_EXPORTER_OTLP_CERTIFICATE"
+	envOTLPInsecure       = "OTEL_EXPORTER_OTLP_INSECURE"
+)
+
+// Exporter names accepted by Config.Exporter.
+const (
+	ExporterOTLP        = "otlp"
+	ExporterJaeger      = "jaeger"
+	ExporterJaegerAgent = "jaeger_agent"
+	ExporterZipkin      = "zipkin"
+	ExporterStdout      = "stdout"
+	ExporterNone        = "none"
+)
+
+// OTLP protocols accepted by Config.Protocol.
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+	ProtocolHTTPJSON     = "http/json"
+)
+
+// CompressionGzip is the only compression accepted by Config.Compression.
+const CompressionGzip = "gzip"
+
+const otlpHTTPTracesPath = "/v1/traces"
+
+// TLSConfig describes the connection security of network exporters.
+type TLSConfig struct {
+	// Insecure disables TLS. An http:// endpoint implies it.
+	Insecure bool `json:"insecure" yaml:"insecure"`
+	// CAFile is a PEM bundle used instead of the system roots.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+	// CertFile and KeyFile hold a client certificate for mutual TLS.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+
+	InsecureSkipVerify bool `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+}
+
+func (t TLSConfig) clientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tracer: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tracer: CA file %s contains no PEM certificate", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tracer: load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Duration is a time.Duration written as a string such as "10s" in
+// configuration files.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10s\": %w", err)
+	}
+	return d.set(s)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.set(value.Value)
+}
+
+func (d *Duration) set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// NewExporter returns the span exporter selected by cfg, or nil for
+// ExporterNone.
+func NewExporter(ctx context.Context, cfg Config) (tracesdk.SpanExporter, error) {
+	endpoint := cfg.endpoint()
+
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		return newOTLPExporter(ctx, cfg, endpoint)
+	case ExporterJaeger:
+		client, err := cfg.httpClient()
+		if err != nil {
+			return nil, err
+		}
+		return jaeger.NewRawExporter(jaeger.WithCollectorEndpoint(
+			jaeger.WithEndpoint(endpoint),
+			jaeger.WithHTTPClient(client),
+		))
+	case ExporterJaegerAgent:
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return jaeger.NewRawExporter(jaeger.WithAgentEndpoint(
+			jaeger.WithAgentHost(host),
+			jaeger.WithAgentPort(port),
+		))
+	case ExporterZipkin:
+		client, err := cfg.httpClient()
+		if err != nil {
+			return nil, err
+		}
+		return zipkin.NewRawExporter(endpoint, zipkin.WithClient(client))
+	case ExporterStdout:
+		return stdout.NewExporter(stdout.WithPrettyPrint())
+	case ExporterNone:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("tracer: unknown exporter %q", cfg.Exporter)
+}
+
+func newOTLPExporter(ctx context.Context, cfg Config, endpoint string) (*otlp.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	insecure := cfg.TLS.Insecure || u.Scheme == "http"
+
+	tlsCfg, err := cfg.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var driver otlp.ProtocolDriver
+	switch cfg.Protocol {
+	case ProtocolGRPC:
+		opts := []otlpgrpc.Option{
+			otlpgrpc.WithEndpoint(u.Host),
+			otlpgrpc.WithHeaders(cfg.Headers),
+		}
+		if insecure {
+			opts = append(opts, otlpgrpc.WithInsecure())
+		} else {
+			opts = append(opts, otlpgrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+		}
+		if cfg.Compression == CompressionGzip {
+			opts = append(opts, otlpgrpc.WithCompressor(CompressionGzip))
+		}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlpgrpc.WithTimeout(time.Duration(cfg.Timeout)))
+		}
+		driver = otlpgrpc.NewDriver(opts...)
+	default:
+		path := u.Path
+		if path == "" {
+			path = otlpHTTPTracesPath
+		}
+		opts := []otlphttp.Option{
+			otlphttp.WithEndpoint(u.Host),
+			otlphttp.WithTracesURLPath(path),
+			otlphttp.WithHeaders(cfg.Headers),
+		}
+		if insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		} else {
+			opts = append(opts, otlphttp.WithTLSClientConfig(tlsCfg))
+		}
+		if cfg.Compression == CompressionGzip {
+			opts = append(opts, otlphttp.WithCompression(otlp.GzipCompression))
+		}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlphttp.WithTimeout(time.Duration(cfg.Timeout)))
+		}
+		if cfg.Protocol == ProtocolHTTPJSON {
+			opts = append(opts, otlphttp.WithMarshal(otlp.MarshalJSON))
+		}
+		driver = otlphttp.NewDriver(opts...)
+	}
+
+	return otlp.NewExporter(ctx, driver)
+}
+
+// httpClient returns the client the Jaeger collector and Zipkin exporters
+// send with, honouring the headers, TLS and timeout settings.
+func (c Config) httpClient() (*http.Client, error) {
+	tlsCfg, err := c.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+
+	var rt http.RoundTripper = transport
+	if len(c.Headers) > 0 {
+		rt = &headerTransport{headers: c.Headers, next: transport}
+	}
+
+	return &http.Client{Transport: rt, Timeout: time.Duration(c.Timeout)}, nil
+}
+
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// readExporterEnv reads the settings of the exporter selected so far, so
+// that e.g. OTEL_EXPORTER_OTLP_ENDPOINT is ignored when exporting to Jaeger.
+func (c *Config) readExporterEnv(lookup func(string) (string, bool)) error {
+	switch c.Exporter {
+	case ExporterJaeger:
+		if v, ok := lookup(envJaegerEndpoint); ok && v != "" {
+			c.Endpoint = v
+			c.setSource("endpoint", envJaegerEndpoint)
+		}
+	case ExporterJaegerAgent:
+		host, hostOK := lookup(envJaegerAgentHost)
+		port, portOK := lookup(envJaegerAgentPort)
+		if hostOK || portOK {
+			if host == "" {
+				host = "localhost"
+			}
+			if port == "" {
+				port = "6831"
+			}
+			c.Endpoint = net.JoinHostPort(host, port)
+			c.setSource("endpoint", envJaegerAgentHost+"/"+envJaegerAgentPort)
+		}
+	case ExporterZipkin:
+		if v, ok := lookup(envZipkinEndpoint); ok && v != "" {
+			c.Endpoint = v
+			c.setSource("endpoint", envZipkinEndpoint)
+		}
+	case ExporterOTLP:
+		return c.readOTLPEnv(lookup)
+	}
+	return nil
+}
+
+func (c *Config) readOTLPEnv(lookup func(string) (string, bool)) error {
+	if v, ok := lookup(envOTLPProtocol); ok && v != "" {
+		c.Protocol = strings.ToLower(v)
+		c.setSource("protocol", envOTLPProtocol)
+	}
+	if v, ok := lookup(envOTLPEndpoint); ok && v != "" {
+		c.Endpoint = v
+		// The generic endpoint is a base URL; OTLP/HTTP appends the signal path.
+		if c.Protocol != ProtocolGRPC {
+			c.Endpoint = strings.TrimSuffix(v, "/") + otlpHTTPTracesPath
+		}
+		c.setSource("endpoint", envOTLPEndpoint)
+	}
+	if v, ok := lookup(envOTLPTracesEndpoint); ok && v != "" {
+		c.Endpoint = v
+		c.setSource("endpoint", envOTLPTracesEndpoint)
+	}
+	if v, ok := lookup(envOTLPHeaders); ok && v != "" {
+		headers, err := parseKeyValues(envOTLPHeaders, v)
+		if err != nil {
+			return err
+		}
+		c.Headers = headers
+		c.setSource("headers", envOTLPHeaders)
+	}
+	if v, ok := lookup(envOTLPCompression); ok && v != "" {
+		c.Compression = strings.ToLower(v)
+		if c.Compression == "none" {
+			c.Compression = ""
+		}
+		c.setSource("compression", envOTLPCompression)
+	}
+	if v, ok := lookup(envOTLPTimeout); ok && v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return &ConfigError{Setting: envOTLPTimeout, Value: v, Reason: "want a number of milliseconds"}
+		}
+		c.Timeout = Duration(time.Duration(ms) * time.Millisecond)
+		c.setSource("timeout", envOTLPTimeout)
+	}
+	if v, ok := lookup(envOTLPCertificate); ok && v != "" {
+		c.TLS.CAFile = v
+		c.setSource("tls.ca_file", envOTLPCertificate)
+	}
+	if v, ok := lookup(envOTLPInsecure); ok && v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return &ConfigError{Setting: envOTLPInsecure, Value: v, Reason: "want true or false"}
+		}
+		c.TLS.Insecure = insecure
+		c.setSource("tls.insecure", envOTLPInsecure)
+	}
+	return nil
+}
+
+func (c Config) validateExporter() error {
+	endpoint := c.endpoint()
+
+	switch c.Exporter {
+	case ExporterJaeger, ExporterZipkin:
+		if err := validateURL(c.setting("endpoint"), endpoint); err != nil {
+			return err
+		}
+	case ExporterJaegerAgent:
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return &ConfigError{Setting: c.setting("endpoint"), Value: endpoint, Reason: "want host:port such as localhost:6831"}
+		}
+	case ExporterOTLP:
+		switch c.Protocol {
+		case ProtocolGRPC, ProtocolHTTPProtobuf, ProtocolHTTPJSON:
+		default:
+			return &ConfigError{
+				Setting: c.setting("protocol"),
+				Value:   c.Protocol,
+				Reason:  fmt.Sprintf("want one of %s", strings.Join([]string{ProtocolGRPC, ProtocolHTTPProtobuf, ProtocolHTTPJSON}, ", ")),
+			}
+		}
+		if err := validateURL(c.setting("endpoint"), endpoint); err != nil {
+			return err
+		}
+	case ExporterStdout, ExporterNone:
+	default:
+		return &ConfigError{
+			Setting: c.setting("exporter"),
+			Value:   c.Exporter,
+			Reason: fmt.Sprintf("want one of %s", strings.Join([]string{
+				ExporterOTLP, ExporterJaeger, ExporterJaegerAgent, ExporterZipkin, ExporterStdout, ExporterNone,
+			}, ", ")),
+		}
+	}
+
+	switch {
+	case c.Compression != "" && c.Compression != CompressionGzip:
+		return &ConfigError{Setting: c.setting("compression"), Value: c.Compression, Reason: "want gzip or none"}
+	case c.Compression != "" && c.Exporter != ExporterOTLP:
+		return &ConfigError{Setting: c.setting("compression"), Value: c.Compression, Reason: "only the otlp exporter supports compression"}
+	}
+
+	if c.Timeout < 0 {
+		return &ConfigError{Setting: c.setting("timeout"), Value: time.Duration(c.Timeout).String(), Reason: "must not be negative"}
+	}
+
+	for _, f := range []struct{ key, path string }{
+		{"tls.ca_file", c.TLS.CAFile},
+		{"tls.cert_file", c.TLS.CertFile},
+		{"tls.key_file", c.TLS.KeyFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			return &ConfigError{Setting: c.setting(f.key), Value: f.path, Reason: "file does not exist"}
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return &ConfigError{Setting: c.setting("tls.cert_file"), Value: c.TLS.CertFile, Reason: "cert_file and key_file must be set together"}
+	}
+
+	return nil
+}
+
+// endpoint returns Endpoint, or the usual local endpoint of the exporter.
+func (c Config) endpoint() string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+
+	switch c.Exporter {
+	case ExporterJaeger:
+		return "http://localhost:14268/api/traces"
+	case ExporterJaegerAgent:
+		return "localhost:6831"
+	case ExporterZipkin:
+		return "http://localhost:9411/api/v2/spans"
+	case ExporterOTLP:
+		if c.Protocol == ProtocolGRPC {
+			return "http://localhost:4317"
+		}
+		return "http://localhost:4318" + otlpHTTPTracesPath
+	}
+	return ""
+}
+
+func validateURL(setting, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return &ConfigError{Setting: setting, Value: raw, Reason: "want an absolute URL such as http://localhost:4318/v1/traces"}
+	}
+	return nil
+}
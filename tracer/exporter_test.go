@@ -0,0 +1,211 @@
+package tracer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const otlpTraceExportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+// fakeReceiver records what OTLP exporters send to it.
+type fakeReceiver struct {
+	mu       sync.Mutex
+	paths    []string
+	headers  []map[string]string
+	payloads [][]byte
+}
+
+func (r *fakeReceiver) record(path string, headers map[string]string, payload []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, path)
+	r.headers = append(r.headers, headers)
+	r.payloads = append(r.payloads, payload)
+}
+
+func (r *fakeReceiver) requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.paths)
+}
+
+// ServeHTTP implements an OTLP/HTTP receiver.
+func (r *fakeReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body []byte
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ = ioutil.ReadAll(zr)
+	} else {
+		body, _ = ioutil.ReadAll(req.Body)
+	}
+
+	r.record(req.URL.Path, map[string]string{
+		"x-api-key":    req.Header.Get("X-Api-Key"),
+		"content-type": req.Header.Get("Content-Type"),
+	}, body)
+	w.WriteHeader(http.StatusOK)
+}
+
+// rawCodec passes gRPC messages through as bytes, so the fake receiver does
+// not need the OTLP proto definitions.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) { return *v.(*[]byte), nil }
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+func (rawCodec) Name() string   { return "proto" }
+func (rawCodec) String() string { return "proto" }
+
+// handleStream implements an OTLP/gRPC receiver.
+func (r *fakeReceiver) handleStream(srv interface{}, stream grpc.ServerStream) error {
+	var payload []byte
+	if err := stream.RecvMsg(&payload); err != nil {
+		return err
+	}
+
+	method, _ := grpc.MethodFromServerStream(stream)
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	r.record(method, map[string]string{"x-api-key": strings.Join(md.Get("x-api-key"), ",")}, payload)
+
+	empty := []byte{}
+	return stream.SendMsg(&empty)
+}
+
+func exportOneSpan(t *testing.T, cfg Config) {
+	t.Helper()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	exp, err := NewExporter(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewExporter: %v", err)
+	}
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exp))
+
+	_, span := tp.Tracer("exporter-test").Start(ctx, "exported-span")
+	span.End()
+
+	if err := tp.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	for _, tc := range []struct {
+		protocol    string
+		compression string
+		contentType string
+	}{
+		{ProtocolHTTPProtobuf, "", "application/x-protobuf"},
+		{ProtocolHTTPProtobuf, CompressionGzip, "application/x-protobuf"},
+		{ProtocolHTTPJSON, "", "application/json"},
+	} {
+		t.Run(tc.protocol+"/"+tc.compression, func(t *testing.T) {
+			receiver := &fakeReceiver{}
+			srv := httptest.NewServer(receiver)
+			defer srv.Close()
+
+			cfg := DefaultConfig()
+			cfg.Exporter = ExporterOTLP
+			cfg.Protocol = tc.protocol
+			cfg.Compression = tc.compression
+			cfg.Endpoint = srv.URL + "/custom/traces"
+			cfg.Headers = map[string]string{"X-Api-Key": "secret"}
+
+			exportOneSpan(t, cfg)
+
+			if receiver.requests() != 1 {
+				t.Fatalf("receiver got %d requests, want 1", receiver.requests())
+			}
+			if got := receiver.paths[0]; got != "/custom/traces" {
+				t.Errorf("path = %q, want /custom/traces", got)
+			}
+			if got := receiver.headers[0]["x-api-key"]; got != "secret" {
+				t.Errorf("X-Api-Key = %q, want secret", got)
+			}
+			if got := receiver.headers[0]["content-type"]; got != tc.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tc.contentType)
+			}
+			if !bytes.Contains(receiver.payloads[0], []byte("exported-span")) {
+				t.Errorf("payload does not contain the span name: %q", receiver.payloads[0])
+			}
+		})
+	}
+}
+
+func TestOTLPGRPCExporter(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	receiver := &fakeReceiver{}
+	srv := grpc.NewServer(
+		grpc.CustomCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(receiver.handleStream),
+	)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cfg := DefaultConfig()
+	cfg.Exporter = ExporterOTLP
+	cfg.Protocol = ProtocolGRPC
+	cfg.Compression = CompressionGzip
+	cfg.Endpoint = "http://" + lis.Addr().String()
+	cfg.Headers = map[string]string{"x-api-key": "secret"}
+
+	exportOneSpan(t, cfg)
+
+	if receiver.requests() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", receiver.requests())
+	}
+	if got := receiver.paths[0]; got != otlpTraceExportMethod {
+		t.Errorf("method = %q, want %q", got, otlpTraceExportMethod)
+	}
+	if got := receiver.headers[0]["x-api-key"]; got != "secret" {
+		t.Errorf("x-api-key = %q, want secret", got)
+	}
+	if !bytes.Contains(receiver.payloads[0], []byte("exported-span")) {
+		t.Errorf("payload does not contain the span name: %q", receiver.payloads[0])
+	}
+}
+
+func TestValidateExporterNamesSetting(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Exporter = ExporterZipkin
+	cfg.Compression = CompressionGzip
+	cfg.setSource("compression", envOTLPCompression)
+
+	err := cfg.Validate()
+	ce, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("Validate() = %v, want *ConfigError", err)
+	}
+	if ce.Setting != envOTLPCompression {
+		t.Errorf("Setting = %q, want %q", ce.Setting, envOTLPCompression)
+	}
+}
@@ -0,0 +1,107 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"tracing/metrics"
+)
+
+// instrumentationName is the name of the meter New reports the metrics of
+// the TailSampler with.
+const instrumentationName = "tracing/tracer"
+
+// Names of the metrics of the TailSampler New installs.
+const (
+	// TailSamplingTraces counts decided traces by TailSamplingDecisionKey.
+	TailSamplingTraces = "tracer.tail_sampling.traces"
+	// TailSamplingEvicted counts traces decided early because MaxTraces
+	// was reached.
+	TailSamplingEvicted = "tracer.tail_sampling.traces.evicted"
+	// TailSamplingBuffered is the number of traces waiting for a decision.
+	TailSamplingBuffered = "tracer.tail_sampling.traces.buffered"
+	// TailSamplingSpansDropped counts spans beyond MaxSpansPerTrace.
+	TailSamplingSpansDropped = "tracer.tail_sampling.spans.dropped"
+)
+
+// TailSamplingDecisionKey labels TailSamplingTraces with "kept" or
+// "dropped".
+const TailSamplingDecisionKey = attribute.Key("decision")
+
+// NewMetrics builds a Prometheus exporter labelling every series with the
+// resource of cfg and registers it as the global one. Pass its
+// MeterProvider to New with WithMeterProvider, and to grpctrace and
+// iristrace, so nothing depends on which global provider existed first. If
+// cfg.MetricsAddr is set, the exporter is served at /metrics on that
+// address until the returned stop function is called; pass it to Graceful.
+func NewMetrics(cfg Config) (*metrics.Exporter, func(context.Context) error, error) {
+	exp, err := metrics.NewExporter(metrics.WithResource(newResource(cfg)))
+	if err != nil {
+		return nil, nil, err
+	}
+	metrics.Install(exp)
+
+	if cfg.MetricsAddr == "" {
+		return exp, func(context.Context) error { return nil }, nil
+	}
+
+	l, err := net.Listen("tcp", cfg.MetricsAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exp)
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			otel.Handle(err)
+		}
+	}()
+
+	return exp, srv.Shutdown, nil
+}
+
+// observeTailSampler reports the counters of ts with meter every time
+// metrics are collected.
+func observeTailSampler(meter metric.Meter, ts *TailSampler) {
+	var (
+		traces       metric.Int64SumObserver
+		evicted      metric.Int64SumObserver
+		buffered     metric.Int64UpDownSumObserver
+		spansDropped metric.Int64SumObserver
+	)
+	batch := meter.NewBatchObserver(func(_ context.Context, result metric.BatchObserverResult) {
+		stats := ts.Stats()
+		result.Observe([]attribute.KeyValue{TailSamplingDecisionKey.String("kept")}, traces.Observation(stats.Kept))
+		result.Observe([]attribute.KeyValue{TailSamplingDecisionKey.String("dropped")}, traces.Observation(stats.Dropped))
+		result.Observe(nil,
+			evicted.Observation(stats.Evicted),
+			buffered.Observation(int64(stats.Buffered)),
+			spansDropped.Observation(stats.SpansDropped),
+		)
+	})
+
+	var err error
+	if traces, err = batch.NewInt64SumObserver(TailSamplingTraces,
+		metric.WithDescription("Traces decided by tail sampling")); err != nil {
+		otel.Handle(err)
+	}
+	if evicted, err = batch.NewInt64SumObserver(TailSamplingEvicted,
+		metric.WithDescription("Traces decided early because the tail sampling buffer was full")); err != nil {
+		otel.Handle(err)
+	}
+	if buffered, err = batch.NewInt64UpDownSumObserver(TailSamplingBuffered,
+		metric.WithDescription("Traces waiting for a tail sampling decision")); err != nil {
+		otel.Handle(err)
+	}
+	if spansDropped, err = batch.NewInt64SumObserver(TailSamplingSpansDropped,
+		metric.WithDescription("Spans dropped because their trace had too many buffered spans")); err != nil {
+		otel.Handle(err)
+	}
+}
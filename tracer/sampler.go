@@ -0,0 +1,225 @@
+package tracer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplingRule gives spans that match it their own sampling rate. Every
+// non-empty matcher must match; a rule without matchers matches every span.
+//
+// Method and Route match exactly, or by prefix when they end in "*".
+type SamplingRule struct {
+	// Name identifies the rule in the sampler description.
+	Name string `json:"name" yaml:"name"`
+
+	// Method matches the gRPC full method, e.g. /tracing.HelloService/Pin.
+	Method string `json:"method" yaml:"method"`
+	// Route matches the HTTP route, e.g. "/ping", or the method and route,
+	// e.g. "GET /ping".
+	Route string `json:"route" yaml:"route"`
+	// Attributes match span start attributes by key. A value of "*" only
+	// requires the attribute to be present.
+	Attributes map[string]string `json:"attributes" yaml:"attributes"`
+
+	// Ratio is the fraction of matching traces sampled, in [0, 1]. Zero,
+	// the default, never samples them, so it is invalid with a RateLimit.
+	Ratio float64 `json:"ratio" yaml:"ratio"`
+	// RateLimit caps the matching traces sampled per second; zero means
+	// no limit.
+	RateLimit float64 `json:"rate_limit" yaml:"rate_limit"`
+}
+
+// HealthCheckRules never sample gRPC and HTTP health checks. New prepends
+// them to Config.SamplingRules unless Config.SampleHealthChecks is set.
+var HealthCheckRules = []SamplingRule{
+	{Name: "grpc-health", Method: "/grpc.health.v1.Health/*"},
+	{Name: "http-health", Route: "/health*"},
+}
+
+// RateLimiting returns a Sampler that samples the traces delegate samples,
+// but no more than perSecond of them per second. It uses a token bucket
+// holding up to one second's worth of traces, so short bursts are allowed.
+func RateLimiting(perSecond float64, delegate tracesdk.Sampler) tracesdk.Sampler {
+	return newRateLimiting(perSecond, delegate, time.Now)
+}
+
+func newRateLimiting(perSecond float64, delegate tracesdk.Sampler, now func() time.Time) *rateLimiting {
+	burst := perSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiting{
+		delegate: delegate,
+		rate:     perSecond,
+		burst:    burst,
+		tokens:   burst,
+		last:     now(),
+		now:      now,
+	}
+}
+
+type rateLimiting struct {
+	delegate tracesdk.Sampler
+	rate     float64
+	burst    float64
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (s *rateLimiting) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	result := s.delegate.ShouldSample(p)
+	if result.Decision != tracesdk.RecordAndSample || s.take() {
+		return result
+	}
+	return tracesdk.SamplingResult{
+		Decision:   tracesdk.Drop,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+// take reports whether a token was available, refilling the bucket for the
+// time elapsed since the last call first.
+func (s *rateLimiting) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens += elapsed.Seconds() * s.rate
+		if s.tokens > s.burst {
+			s.tokens = s.burst
+		}
+		s.last = now
+	}
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+func (s *rateLimiting) Description() string {
+	return fmt.Sprintf("RateLimiting{%g/s,%s}", s.rate, s.delegate.Description())
+}
+
+// RuleBased returns a Sampler that samples root spans, those without a
+// parent, at the rate of the first rule they match. Spans with a parent,
+// local or remote, and root spans matching no rule are left to fallback,
+// which decides whether they follow their parent.
+func RuleBased(rules []SamplingRule, fallback tracesdk.Sampler) tracesdk.Sampler {
+	s := &ruleBased{fallback: fallback}
+	for _, r := range rules {
+		var sampler tracesdk.Sampler = tracesdk.TraceIDRatioBased(r.Ratio)
+		if r.RateLimit > 0 {
+			sampler = RateLimiting(r.RateLimit, sampler)
+		}
+		s.rules = append(s.rules, compiledRule{SamplingRule: r, sampler: sampler})
+	}
+	return s
+}
+
+type compiledRule struct {
+	SamplingRule
+	sampler tracesdk.Sampler
+}
+
+type ruleBased struct {
+	rules    []compiledRule
+	fallback tracesdk.Sampler
+}
+
+func (s *ruleBased) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	if !trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		for _, r := range s.rules {
+			if r.matches(p) {
+				return r.sampler.ShouldSample(p)
+			}
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *ruleBased) Description() string {
+	names := make([]string, 0, len(s.rules))
+	for i, r := range s.rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule%d", i)
+		}
+		names = append(names, fmt.Sprintf("%s:%s", name, r.sampler.Description()))
+	}
+	return fmt.Sprintf("RuleBased{rules:[%s],fallback:%s}", strings.Join(names, ","), s.fallback.Description())
+}
+
+func (r SamplingRule) matches(p tracesdk.SamplingParameters) bool {
+	attrs := make(map[attribute.Key]attribute.Value, len(p.Attributes))
+	for _, kv := range p.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+
+	if r.Method != "" && !matchPattern(r.Method, fullMethod(p.Name, attrs)) {
+		return false
+	}
+	if r.Route != "" && !matchRoute(r.Route, p.Name, attrs) {
+		return false
+	}
+	for k, want := range r.Attributes {
+		v, ok := attrs[attribute.Key(k)]
+		if !ok || (want != "*" && v.Emit() != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// fullMethod rebuilds the gRPC full method from the rpc.service and
+// rpc.method attributes, falling back to the span name, which grpctrace
+// sets to the full method without its leading slash.
+func fullMethod(name string, attrs map[attribute.Key]attribute.Value) string {
+	service, ok1 := attrs[semconv.RPCServiceKey]
+	method, ok2 := attrs[semconv.RPCMethodKey]
+	if ok1 && ok2 {
+		return "/" + service.AsString() + "/" + method.AsString()
+	}
+	return "/" + strings.TrimLeft(name, "/")
+}
+
+// matchRoute matches the http.route attribute, or the path of span names
+// like "GET /ping", against pattern. Patterns that start with an HTTP
+// method are matched against the method and route together.
+func matchRoute(pattern, name string, attrs map[attribute.Key]attribute.Value) bool {
+	method, route := "", ""
+	if v, ok := attrs[semconv.HTTPRouteKey]; ok {
+		route = v.AsString()
+		if m, ok := attrs[semconv.HTTPMethodKey]; ok {
+			method = m.AsString()
+		}
+	} else if i := strings.IndexByte(name, ' '); i > 0 {
+		method, route = name[:i], name[i+1:]
+	} else {
+		route = name
+	}
+
+	if strings.HasPrefix(pattern, "/") {
+		return matchPattern(pattern, route)
+	}
+	return method != "" && matchPattern(pattern, method+" "+route)
+}
+
+func matchPattern(pattern, s string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(s, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == s
+}
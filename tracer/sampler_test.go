@@ -0,0 +1,195 @@
+package tracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testTraceID = trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	testSpanID  = trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+)
+
+// params returns the sampling parameters of a span named name with attrs,
+// started in ctx.
+func params(ctx context.Context, name string, attrs ...attribute.KeyValue) tracesdk.SamplingParameters {
+	return tracesdk.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       testTraceID,
+		Name:          name,
+		Kind:          trace.SpanKindServer,
+		Attributes:    attrs,
+	}
+}
+
+func parentContext(sampled, remote bool) context.Context {
+	cfg := trace.SpanContextConfig{TraceID: testTraceID, SpanID: testSpanID}
+	if sampled {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(cfg)
+	if remote {
+		return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+	}
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func sampled(s tracesdk.Sampler, p tracesdk.SamplingParameters) bool {
+	return s.ShouldSample(p).Decision == tracesdk.RecordAndSample
+}
+
+func TestRateLimiting(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	s := newRateLimiting(2, tracesdk.AlwaysSample(), clock.Now)
+	p := params(context.Background(), "op")
+
+	take := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if sampled(s, p) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := take(); n != 2 {
+		t.Errorf("sampled %d of a burst, want the 2 tokens of a full bucket", n)
+	}
+	clock.Advance(250 * time.Millisecond)
+	if n := take(); n != 0 {
+		t.Errorf("sampled %d after 250ms, want 0 until a whole token refilled", n)
+	}
+	clock.Advance(250 * time.Millisecond)
+	if n := take(); n != 1 {
+		t.Errorf("sampled %d after 500ms, want 1", n)
+	}
+	clock.Advance(time.Hour)
+	if n := take(); n != 2 {
+		t.Errorf("sampled %d after an hour, want the burst of 2", n)
+	}
+}
+
+func TestRateLimitingBelowOnePerSecond(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	s := newRateLimiting(0.5, tracesdk.AlwaysSample(), clock.Now)
+	p := params(context.Background(), "op")
+
+	if !sampled(s, p) || sampled(s, p) {
+		t.Fatal("want one trace sampled from a burst of 1")
+	}
+	clock.Advance(time.Second)
+	if sampled(s, p) {
+		t.Error("sampled after 1s at 0.5/s")
+	}
+	clock.Advance(time.Second)
+	if !sampled(s, p) {
+		t.Error("not sampled after 2s at 0.5/s")
+	}
+}
+
+func TestRateLimitingDelegateDrops(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	s := newRateLimiting(1, tracesdk.NeverSample(), clock.Now)
+	if sampled(s, params(context.Background(), "op")) {
+		t.Fatal("sampled a trace the delegate dropped")
+	}
+	// Dropped traces use no token.
+	s.delegate = tracesdk.AlwaysSample()
+	if !sampled(s, params(context.Background(), "op")) {
+		t.Error("token was used by a dropped trace")
+	}
+}
+
+func TestRuleBasedPrecedence(t *testing.T) {
+	rules := []SamplingRule{
+		{Name: "checkout", Route: "/checkout", Attributes: map[string]string{"tenant": "acme"}, Ratio: 1},
+		{Name: "checkout-others", Route: "/checkout"},
+		{Name: "pin", Method: "/tracing.HelloService/Pin", Ratio: 1},
+		{Name: "hello", Method: "/tracing.HelloService/*"},
+		{Name: "debug", Attributes: map[string]string{"debug": "*"}, Ratio: 1},
+	}
+	s := RuleBased(rules, tracesdk.NeverSample())
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		attrs []attribute.KeyValue
+		want  bool
+	}{
+		{"GET /checkout", []attribute.KeyValue{attribute.String("tenant", "acme")}, true},
+		{"GET /checkout", []attribute.KeyValue{attribute.String("tenant", "other")}, false},
+		{"GET /checkout", nil, false},
+		// The route attribute wins over the span name.
+		{"GET", []attribute.KeyValue{semconv.HTTPRouteKey.String("/checkout"), attribute.String("tenant", "acme")}, true},
+		{"tracing.HelloService/Pin", nil, true},
+		{"tracing.HelloService/Other", nil, false},
+		// The rpc attributes win over the span name.
+		{"ignored", []attribute.KeyValue{semconv.RPCServiceKey.String("tracing.HelloService"), semconv.RPCMethodKey.String("Pin")}, true},
+		// Earlier rules win over later ones.
+		{"tracing.HelloService/Other", []attribute.KeyValue{attribute.String("debug", "1")}, false},
+		{"GET /other", []attribute.KeyValue{attribute.String("debug", "")}, true},
+		// Spans matching no rule are left to the fallback.
+		{"GET /other", nil, false},
+	}
+	for _, tt := range tests {
+		if got := sampled(s, params(ctx, tt.name, tt.attrs...)); got != tt.want {
+			t.Errorf("%q %v: sampled = %v, want %v", tt.name, tt.attrs, got, tt.want)
+		}
+	}
+}
+
+func TestRuleBasedOnlyRoots(t *testing.T) {
+	rules := []SamplingRule{{Name: "drop", Route: "/checkout"}}
+	s := RuleBased(rules, tracesdk.ParentBased(tracesdk.AlwaysSample()))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"root", context.Background(), false},
+		{"remote sampled parent", parentContext(true, true), true},
+		{"remote unsampled parent", parentContext(false, true), false},
+		{"local sampled parent", parentContext(true, false), true},
+	}
+	for _, tt := range tests {
+		if got := sampled(s, params(tt.ctx, "GET /checkout")); got != tt.want {
+			t.Errorf("%s: sampled = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewSamplerRateLimitsRootsOnly(t *testing.T) {
+	for _, name := range []string{SamplerAlwaysOn, SamplerParentBasedAlwaysOn} {
+		t.Run(name, func(t *testing.T) {
+			s := newSampler(Config{Sampler: name, RateLimit: 1, SampleHealthChecks: true})
+
+			if !sampled(s, params(context.Background(), "root")) {
+				t.Fatal("first root not sampled")
+			}
+			if sampled(s, params(context.Background(), "root")) {
+				t.Error("second root sampled over the limit")
+			}
+			// Children of the sampled root are not limited.
+			for i := 0; i < 3; i++ {
+				if !sampled(s, params(parentContext(true, false), "child")) {
+					t.Fatal("child of a sampled root dropped by the limit")
+				}
+			}
+		})
+	}
+
+	// always_on ignores the decision of remote parents, with or without a
+	// limit.
+	s := newSampler(Config{Sampler: SamplerAlwaysOn, RateLimit: 1, SampleHealthChecks: true})
+	if !sampled(s, params(parentContext(false, true), "server")) {
+		t.Error("always_on followed an unsampled remote parent")
+	}
+}
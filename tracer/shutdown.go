@@ -0,0 +1,139 @@
+package tracer
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Stats counts what happened to the sampled spans of a provider built by
+// New. Dropped spans ended but never reached the exporter, e.g. because the
+// batch queue was full or the shutdown deadline passed.
+type Stats struct {
+	Ended    int64
+	Exported int64
+	Failed   int64
+	Dropped  int64
+
+	// TailSampling counts the decisions of the TailSampler, if
+	// Config.TailSampling enabled one.
+	TailSampling TailSamplingStats
+}
+
+// ShutdownFunc force-flushes and shuts down a provider built by New. It
+// waits at most until ctx is done or Config.ShutdownTimeout elapsed,
+// whichever comes first.
+type ShutdownFunc func(ctx context.Context) (Stats, error)
+
+// spanCounter is a SpanProcessor counting sampled spans as they end; a
+// countingExporter adds the outcome of every export.
+type spanCounter struct {
+	ended    int64
+	exported int64
+	failed   int64
+}
+
+var _ tracesdk.SpanProcessor = (*spanCounter)(nil)
+
+func (c *spanCounter) OnStart(context.Context, tracesdk.ReadWriteSpan) {}
+
+func (c *spanCounter) OnEnd(s tracesdk.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		atomic.AddInt64(&c.ended, 1)
+	}
+}
+
+func (c *spanCounter) ForceFlush(context.Context) error { return nil }
+
+func (c *spanCounter) Shutdown(context.Context) error { return nil }
+
+func (c *spanCounter) stats() Stats {
+	s := Stats{
+		Ended:    atomic.LoadInt64(&c.ended),
+		Exported: atomic.LoadInt64(&c.exported),
+		Failed:   atomic.LoadInt64(&c.failed),
+	}
+	s.Dropped = s.Ended - s.Exported - s.Failed
+	return s
+}
+
+// countingExporter reports exports to a spanCounter.
+type countingExporter struct {
+	tracesdk.SpanExporter
+	counter *spanCounter
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []*tracesdk.SpanSnapshot) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		atomic.AddInt64(&e.counter.failed, int64(len(spans)))
+	} else {
+		atomic.AddInt64(&e.counter.exported, int64(len(spans)))
+	}
+	return err
+}
+
+func newShutdownFunc(tp *tracesdk.TracerProvider, counter *spanCounter, tail *TailSampler, timeout time.Duration) ShutdownFunc {
+	return func(ctx context.Context) (Stats, error) {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		err := tp.ForceFlush(ctx)
+		if serr := tp.Shutdown(ctx); err == nil {
+			err = serr
+		}
+
+		var stats Stats
+		if counter != nil {
+			stats = counter.stats()
+		}
+		if tail != nil {
+			stats.TailSampling = tail.Stats()
+		}
+		return stats, err
+	}
+}
+
+// Graceful blocks until the process receives SIGINT or SIGTERM, or ctx is
+// done. It then calls every stop in order, e.g. to drain servers so their
+// last spans end, and finally shutdown. All of them share timeout; a
+// timeout <= 0 means no deadline, as for Config.ShutdownTimeout.
+func Graceful(ctx context.Context, timeout time.Duration, shutdown ShutdownFunc, stops ...func(context.Context) error) (Stats, error) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case <-sig:
+	case <-ctx.Done():
+	}
+
+	ctx = context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var firstErr error
+	for _, stop := range stops {
+		if err := stop(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	stats, err := shutdown(ctx)
+	if firstErr == nil {
+		firstErr = err
+	}
+
+	return stats, firstErr
+}
@@ -0,0 +1,57 @@
+package tracer
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestGracefulWithoutTimeout(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	receiver := &fakeReceiver{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.Exporter = ExporterOTLP
+	cfg.Protocol = ProtocolHTTPProtobuf
+	cfg.Endpoint = srv.URL + "/v1/traces"
+	cfg.ShutdownTimeout = 0
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tp, shutdown, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The span waits in the batch queue until shutdown flushes it.
+	_, span := tp.Tracer("shutdown_test").Start(context.Background(), "queued")
+	span.End()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stop := func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok || ctx.Err() != nil {
+			t.Errorf("stop got a context with a deadline or done: %v", ctx.Err())
+		}
+		return nil
+	}
+	stats, err := Graceful(ctx, 0, shutdown, stop)
+	if err != nil {
+		t.Fatalf("Graceful: %v", err)
+	}
+	if stats.Exported != 1 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want 1 span exported and none dropped", stats)
+	}
+	if receiver.requests() != 1 {
+		t.Errorf("receiver got %d requests, want 1", receiver.requests())
+	}
+}
@@ -0,0 +1,354 @@
+package tracer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TailSamplingConfig configures the TailSampler New installs when Enabled
+// is set. A trace is kept when any of its spans has an error status, it
+// lasts at least Latency, one of its spans matches an attribute policy, or
+// it falls in Ratio; other traces are dropped.
+//
+// Tail sampling only sees the spans the head sampler samples, so it is
+// usually combined with the always_on or parentbased_always_on sampler.
+type TailSamplingConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// DecisionWait is how long spans are buffered after the first span of
+	// their trace ends before the trace is decided. It defaults to 10s.
+	DecisionWait Duration `json:"decision_wait" yaml:"decision_wait"`
+
+	// KeepErrors keeps traces with a span whose status is codes.Error.
+	KeepErrors bool `json:"keep_errors" yaml:"keep_errors"`
+	// Latency keeps traces lasting at least this long; zero disables it.
+	Latency Duration `json:"latency" yaml:"latency"`
+	// Attributes keep traces with a span matching any of the policies.
+	Attributes []AttributePolicy `json:"attributes" yaml:"attributes"`
+	// Ratio is the fraction of the remaining traces kept anyway, in [0, 1].
+	Ratio float64 `json:"ratio" yaml:"ratio"`
+
+	// MaxTraces bounds the traces buffered at once. When it is reached the
+	// oldest trace is decided early and counted as evicted. It defaults
+	// to 10000.
+	MaxTraces int `json:"max_traces" yaml:"max_traces"`
+	// MaxSpansPerTrace bounds the spans buffered per trace. Further spans
+	// still count towards the decision but are dropped. It defaults to
+	// 1000.
+	MaxSpansPerTrace int `json:"max_spans_per_trace" yaml:"max_spans_per_trace"`
+}
+
+// Defaults of the TailSamplingConfig limits left at zero.
+const (
+	defaultDecisionWait     = Duration(10 * time.Second)
+	defaultMaxTraces        = 10000
+	defaultMaxSpansPerTrace = 1000
+)
+
+// AttributePolicy matches spans having attribute Key with one of Values,
+// or with any value when Values is empty.
+type AttributePolicy struct {
+	Key    string   `json:"key" yaml:"key"`
+	Values []string `json:"values" yaml:"values"`
+}
+
+// TailSamplingStats counts what a TailSampler did so far.
+type TailSamplingStats struct {
+	// Buffered is the number of traces waiting for a decision.
+	Buffered int
+	Kept     int64
+	Dropped  int64
+	// Evicted traces were decided before DecisionWait elapsed because
+	// MaxTraces was reached.
+	Evicted int64
+	// SpansDropped counts spans beyond MaxSpansPerTrace.
+	SpansDropped int64
+	// LateSpans ended after their trace was decided; they follow the
+	// decision.
+	LateSpans int64
+}
+
+// TailSampler is a SpanProcessor buffering sampled spans per trace and
+// passing the spans of the traces it keeps on to the next processors.
+type TailSampler struct {
+	cfg   TailSamplingConfig
+	next  []tracesdk.SpanProcessor
+	ratio tracesdk.Sampler
+	now   func() time.Time
+
+	mu      sync.Mutex
+	traces  map[trace.TraceID]*tailTrace
+	order   []trace.TraceID
+	decided map[trace.TraceID]bool
+	history []trace.TraceID
+	stats   TailSamplingStats
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+var _ tracesdk.SpanProcessor = (*TailSampler)(nil)
+
+type tailTrace struct {
+	first time.Time
+	spans []tracesdk.ReadOnlySpan
+
+	start, end time.Time
+	keep       bool
+}
+
+// NewTailSampler returns a TailSampler forwarding the spans of kept traces
+// to next, typically a batch span processor. It decides traces in the
+// background until Shutdown is called.
+func NewTailSampler(cfg TailSamplingConfig, next ...tracesdk.SpanProcessor) *TailSampler {
+	ts := newTailSampler(cfg, time.Now, next...)
+
+	interval := time.Duration(ts.cfg.DecisionWait) / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ts.done = make(chan struct{})
+	go ts.run(interval)
+
+	return ts
+}
+
+func newTailSampler(cfg TailSamplingConfig, now func() time.Time, next ...tracesdk.SpanProcessor) *TailSampler {
+	if cfg.DecisionWait <= 0 {
+		cfg.DecisionWait = defaultDecisionWait
+	}
+	if cfg.MaxTraces <= 0 {
+		cfg.MaxTraces = defaultMaxTraces
+	}
+	if cfg.MaxSpansPerTrace <= 0 {
+		cfg.MaxSpansPerTrace = defaultMaxSpansPerTrace
+	}
+
+	return &TailSampler{
+		cfg:     cfg,
+		next:    next,
+		ratio:   tracesdk.TraceIDRatioBased(cfg.Ratio),
+		now:     now,
+		traces:  map[trace.TraceID]*tailTrace{},
+		decided: map[trace.TraceID]bool{},
+		stop:    make(chan struct{}),
+	}
+}
+
+func (ts *TailSampler) run(interval time.Duration) {
+	defer close(ts.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ts.decide(false)
+		case <-ts.stop:
+			return
+		}
+	}
+}
+
+// OnStart passes s on to the next processors.
+func (ts *TailSampler) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	for _, p := range ts.next {
+		p.OnStart(parent, s)
+	}
+}
+
+// OnEnd buffers s until its trace is decided.
+func (ts *TailSampler) OnEnd(s tracesdk.ReadOnlySpan) {
+	sc := s.SpanContext()
+	if !sc.IsSampled() {
+		ts.forward([]tracesdk.ReadOnlySpan{s})
+		return
+	}
+
+	ts.mu.Lock()
+	if keep, ok := ts.decided[sc.TraceID()]; ok {
+		ts.stats.LateSpans++
+		ts.mu.Unlock()
+		if keep {
+			ts.forward([]tracesdk.ReadOnlySpan{s})
+		}
+		return
+	}
+
+	var kept []tracesdk.ReadOnlySpan
+	t, ok := ts.traces[sc.TraceID()]
+	if !ok {
+		if len(ts.traces) >= ts.cfg.MaxTraces {
+			kept = ts.evictLocked()
+		}
+		t = &tailTrace{first: ts.now(), start: s.StartTime(), end: s.EndTime()}
+		ts.traces[sc.TraceID()] = t
+		ts.order = append(ts.order, sc.TraceID())
+	}
+	ts.observeLocked(t, s)
+	if len(t.spans) >= ts.cfg.MaxSpansPerTrace {
+		ts.stats.SpansDropped++
+	} else {
+		t.spans = append(t.spans, s)
+	}
+	kept = append(kept, ts.expiredLocked(false)...)
+	ts.mu.Unlock()
+
+	ts.forward(kept)
+}
+
+// observeLocked applies the policies that depend on single spans.
+func (ts *TailSampler) observeLocked(t *tailTrace, s tracesdk.ReadOnlySpan) {
+	if s.StartTime().Before(t.start) {
+		t.start = s.StartTime()
+	}
+	if s.EndTime().After(t.end) {
+		t.end = s.EndTime()
+	}
+	if t.keep {
+		return
+	}
+	if ts.cfg.KeepErrors && s.StatusCode() == codes.Error {
+		t.keep = true
+		return
+	}
+	for _, kv := range s.Attributes() {
+		for _, p := range ts.cfg.Attributes {
+			if p.matches(string(kv.Key), kv.Value.Emit()) {
+				t.keep = true
+				return
+			}
+		}
+	}
+}
+
+func (p AttributePolicy) matches(key, value string) bool {
+	if key != p.Key {
+		return false
+	}
+	if len(p.Values) == 0 {
+		return true
+	}
+	for _, v := range p.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// decide decides the traces whose DecisionWait elapsed, or all buffered
+// traces when all is set, and forwards the spans of those kept.
+func (ts *TailSampler) decide(all bool) {
+	ts.mu.Lock()
+	kept := ts.expiredLocked(all)
+	ts.mu.Unlock()
+
+	ts.forward(kept)
+}
+
+// expiredLocked decides traces in the order they arrived, which is also the
+// order their DecisionWait elapses in.
+func (ts *TailSampler) expiredLocked(all bool) []tracesdk.ReadOnlySpan {
+	var kept []tracesdk.ReadOnlySpan
+	now := ts.now()
+	for len(ts.order) > 0 {
+		t := ts.traces[ts.order[0]]
+		if !all && now.Sub(t.first) < time.Duration(ts.cfg.DecisionWait) {
+			break
+		}
+		kept = append(kept, ts.popLocked()...)
+	}
+	return kept
+}
+
+func (ts *TailSampler) evictLocked() []tracesdk.ReadOnlySpan {
+	ts.stats.Evicted++
+	return ts.popLocked()
+}
+
+// popLocked decides the oldest buffered trace and returns its spans if it
+// is kept.
+func (ts *TailSampler) popLocked() []tracesdk.ReadOnlySpan {
+	id := ts.order[0]
+	ts.order = ts.order[1:]
+	t := ts.traces[id]
+	delete(ts.traces, id)
+
+	keep := t.keep ||
+		(ts.cfg.Latency > 0 && t.end.Sub(t.start) >= time.Duration(ts.cfg.Latency)) ||
+		ts.ratio.ShouldSample(tracesdk.SamplingParameters{ParentContext: context.Background(), TraceID: id}).Decision == tracesdk.RecordAndSample
+
+	// Remember the decision for late spans, for as many traces as may be
+	// buffered.
+	ts.decided[id] = keep
+	ts.history = append(ts.history, id)
+	for len(ts.history) > ts.cfg.MaxTraces {
+		delete(ts.decided, ts.history[0])
+		ts.history = ts.history[1:]
+	}
+
+	if !keep {
+		ts.stats.Dropped++
+		return nil
+	}
+	ts.stats.Kept++
+	return t.spans
+}
+
+func (ts *TailSampler) forward(spans []tracesdk.ReadOnlySpan) {
+	for _, s := range spans {
+		for _, p := range ts.next {
+			p.OnEnd(s)
+		}
+	}
+}
+
+// Stats returns the counters of ts.
+func (ts *TailSampler) Stats() TailSamplingStats {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	stats := ts.stats
+	stats.Buffered = len(ts.traces)
+	return stats
+}
+
+// ForceFlush decides every buffered trace now and flushes the next
+// processors.
+func (ts *TailSampler) ForceFlush(ctx context.Context) error {
+	ts.decide(true)
+	for _, p := range ts.next {
+		if err := p.ForceFlush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown decides every buffered trace, stops deciding in the background
+// and shuts the next processors down.
+func (ts *TailSampler) Shutdown(ctx context.Context) error {
+	ts.stopOnce.Do(func() { close(ts.stop) })
+	if ts.done != nil {
+		select {
+		case <-ts.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ts.decide(true)
+	for _, p := range ts.next {
+		if err := p.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package tracer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"tracing/metrics"
+)
+
+// fakeClock is advanced by hand so decisions happen exactly when a test
+// wants them to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// recorder is a SpanProcessor remembering the names of the spans it got.
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recorder) OnStart(context.Context, tracesdk.ReadWriteSpan) {}
+
+func (r *recorder) OnEnd(s tracesdk.ReadOnlySpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, s.Name())
+}
+
+func (r *recorder) ForceFlush(context.Context) error { return nil }
+
+func (r *recorder) Shutdown(context.Context) error { return nil }
+
+func (r *recorder) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func newTestTailSampler(cfg TailSamplingConfig) (*TailSampler, *fakeClock, *recorder, trace.Tracer) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	rec := &recorder{}
+	ts := newTailSampler(cfg, clock.Now, rec)
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithSampler(tracesdk.AlwaysSample()),
+		tracesdk.WithSpanProcessor(ts),
+	)
+	return ts, clock, rec, tp.Tracer("tail_test")
+}
+
+// endTrace ends a root span named name with one child, both lasting d.
+func endTrace(tr trace.Tracer, name string, d time.Duration, opts ...func(trace.Span)) {
+	start := time.Unix(1600000000, 0)
+	ctx, root := tr.Start(context.Background(), name, trace.WithTimestamp(start))
+	_, child := tr.Start(ctx, name+"/child", trace.WithTimestamp(start))
+	for _, opt := range opts {
+		opt(child)
+	}
+	child.End(trace.WithTimestamp(start.Add(d)))
+	root.End(trace.WithTimestamp(start.Add(d)))
+}
+
+func TestTailSamplerPolicies(t *testing.T) {
+	ts, clock, rec, tr := newTestTailSampler(TailSamplingConfig{
+		DecisionWait: Duration(time.Second),
+		KeepErrors:   true,
+		Latency:      Duration(500 * time.Millisecond),
+		Attributes:   []AttributePolicy{{Key: "user.tier", Values: []string{"gold"}}},
+	})
+
+	endTrace(tr, "ok", time.Millisecond)
+	endTrace(tr, "error", time.Millisecond, func(s trace.Span) { s.SetStatus(codes.Error, "boom") })
+	endTrace(tr, "slow", time.Second)
+	endTrace(tr, "gold", time.Millisecond, func(s trace.Span) { s.SetAttributes(attribute.String("user.tier", "gold")) })
+	endTrace(tr, "silver", time.Millisecond, func(s trace.Span) { s.SetAttributes(attribute.String("user.tier", "silver")) })
+
+	if got := rec.Names(); len(got) != 0 {
+		t.Fatalf("spans forwarded before the decision window elapsed: %v", got)
+	}
+
+	clock.Advance(time.Second)
+	ts.decide(false)
+
+	want := []string{"error/child", "error", "slow/child", "slow", "gold/child", "gold"}
+	if got := rec.Names(); !equalStrings(got, want) {
+		t.Errorf("forwarded %v, want %v", got, want)
+	}
+
+	stats := ts.Stats()
+	if stats.Kept != 3 || stats.Dropped != 2 || stats.Buffered != 0 {
+		t.Errorf("stats = %+v, want 3 kept, 2 dropped, none buffered", stats)
+	}
+}
+
+func TestTailSamplerLateSpansFollowDecision(t *testing.T) {
+	ts, clock, rec, tr := newTestTailSampler(TailSamplingConfig{
+		DecisionWait: Duration(time.Second),
+		KeepErrors:   true,
+	})
+
+	ctx, root := tr.Start(context.Background(), "root")
+	_, child := tr.Start(ctx, "child")
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+
+	clock.Advance(time.Second)
+	ts.decide(false)
+	root.End()
+
+	want := []string{"child", "root"}
+	if got := rec.Names(); !equalStrings(got, want) {
+		t.Errorf("forwarded %v, want %v", got, want)
+	}
+	if stats := ts.Stats(); stats.LateSpans != 1 {
+		t.Errorf("LateSpans = %d, want 1", stats.LateSpans)
+	}
+}
+
+func TestTailSamplerBoundsMemory(t *testing.T) {
+	ts, _, rec, tr := newTestTailSampler(TailSamplingConfig{
+		DecisionWait:     Duration(time.Minute),
+		KeepErrors:       true,
+		MaxTraces:        2,
+		MaxSpansPerTrace: 1,
+	})
+
+	endTrace(tr, "first", time.Millisecond, func(s trace.Span) { s.SetStatus(codes.Error, "boom") })
+	endTrace(tr, "second", time.Millisecond)
+	endTrace(tr, "third", time.Millisecond)
+
+	// Only the child of each trace fits in the buffer. "third" evicts
+	// "first", which is kept for its error.
+	want := []string{"first/child"}
+	if got := rec.Names(); !equalStrings(got, want) {
+		t.Errorf("forwarded %v, want %v", got, want)
+	}
+
+	stats := ts.Stats()
+	if stats.Evicted != 1 || stats.Buffered != 2 || stats.SpansDropped != 3 {
+		t.Errorf("stats = %+v, want 1 evicted, 2 buffered, 3 spans dropped", stats)
+	}
+
+	if err := ts.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := ts.Stats(); stats.Buffered != 0 || stats.Dropped != 2 {
+		t.Errorf("after ForceFlush stats = %+v, want none buffered, 2 dropped", stats)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTailSamplerMetrics(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	cfg := DefaultConfig()
+	cfg.Exporter = ExporterNone
+	cfg.Sampler = SamplerAlwaysOn
+	cfg.TailSampling = TailSamplingConfig{
+		Enabled:      true,
+		DecisionWait: Duration(time.Hour),
+		MaxTraces:    1,
+	}
+	exp, err := metrics.NewExporter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, shutdown, err := New(cfg, WithMeterProvider(exp.MeterProvider()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The buffer holds one trace, so the second evicts the first.
+	tr := tp.Tracer("tail_test")
+	endTrace(tr, "first", time.Millisecond)
+	endTrace(tr, "second", time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	exp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^tracer_tail_sampling_traces_evicted(\{.*\})? 1$`),
+		regexp.MustCompile(`(?m)^tracer_tail_sampling_traces\{decision="dropped".*\} 1$`),
+		regexp.MustCompile(`(?m)^tracer_tail_sampling_traces\{decision="kept".*\} 0$`),
+		regexp.MustCompile(`(?m)^tracer_tail_sampling_traces_buffered(\{.*\})? 1$`),
+	} {
+		if !want.MatchString(body) {
+			t.Errorf("scrape does not match %s:\n%s", want, body)
+		}
+	}
+
+	stats, err := shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TailSampling.Evicted != 1 || stats.TailSampling.Dropped != 2 {
+		t.Errorf("shutdown stats = %+v, want 1 evicted and 2 dropped traces", stats.TailSampling)
+	}
+}
@@ -1,61 +1,160 @@
 package tracer
 
 import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/trace/jaeger"
-	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/semconv"
-)
 
-const (
-	service     = "demo-service-adam"
-	environment = "production"
+	"tracing/propagators"
 )
 
-func TracerProvider(url string) (*tracesdk.TracerProvider, error) {
-	// Create the Jaeger exporter
-	exp, err := jaeger.NewRawExporter(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
-	if err != nil {
-		return nil, err
+// Option specifies options of New that are not part of Config.
+type Option func(*options)
+
+type options struct {
+	MeterProvider metric.MeterProvider
+}
+
+// WithMeterProvider specifies the meter provider the metrics of the
+// TailSampler are recorded with, e.g. the one of the exporter NewMetrics
+// returns. If none is specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.MeterProvider = provider
 	}
+}
 
-	tp := tracesdk.NewTracerProvider(
-		// Always be sure to batch in production.
-		tracesdk.WithBatcher(exp),
+// New builds a TracerProvider from cfg and registers it, together with the
+// propagators cfg selects, as the global one. The
+// returned ShutdownFunc must be called before the process exits, otherwise
+// spans still queued for export are lost.
+func New(cfg Config, opts ...Option) (*tracesdk.TracerProvider, ShutdownFunc, error) {
+	o := options{MeterProvider: global.GetMeterProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	tpOpts := []tracesdk.TracerProviderOption{
 		// Record information about this application in an Resource.
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.ServiceNameKey.String(service),
-			attribute.String("environment", environment),
-		)),
-	)
+		tracesdk.WithResource(newResource(cfg)),
+		tracesdk.WithSampler(newSampler(cfg)),
+	}
+
+	propagator, err := propagators.Composite(cfg.Propagators, cfg.ExtractPropagators)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range cfg.Propagators {
+		if strings.EqualFold(name, propagators.XRay) {
+			// X-Ray rejects trace IDs that do not start with the time
+			// the trace started at.
+			tpOpts = append(tpOpts, tracesdk.WithIDGenerator(xray.NewIDGenerator()))
+			break
+		}
+	}
+
+	exp, err := NewExporter(context.Background(), cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var counter *spanCounter
+	var processors []tracesdk.SpanProcessor
+	if exp != nil {
+		counter = &spanCounter{}
+		exp = &countingExporter{SpanExporter: exp, counter: counter}
+		processors = append(processors, counter)
+		if cfg.Exporter == ExporterStdout {
+			processors = append(processors, tracesdk.NewSimpleSpanProcessor(exp))
+		} else {
+			// Always be sure to batch in production.
+			processors = append(processors, tracesdk.NewBatchSpanProcessor(exp))
+		}
+	}
+	var tail *TailSampler
+	if cfg.TailSampling.Enabled {
+		// Spans of the traces tail sampling drops are not counted as
+		// ended, so Stats.Dropped only reports export losses.
+		tail = NewTailSampler(cfg.TailSampling, processors...)
+		processors = []tracesdk.SpanProcessor{tail}
+		observeTailSampler(o.MeterProvider.Meter(instrumentationName), tail)
+	}
+	for _, p := range processors {
+		tpOpts = append(tpOpts, tracesdk.WithSpanProcessor(p))
+	}
+
+	tp := tracesdk.NewTracerProvider(tpOpts...)
 
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTextMapPropagator(propagator)
+
+	return tp, newShutdownFunc(tp, counter, tail, time.Duration(cfg.ShutdownTimeout)), nil
+}
+
+func newResource(cfg Config) *resource.Resource {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+		attribute.String("environment", cfg.Environment),
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(cfg.ServiceVersion))
+	}
+	for k, v := range cfg.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
 
-	return tp, nil
+	return resource.NewWithAttributes(attrs...)
 }
 
-// NewTracing -
-//func NewTracer() {
-//exporter, err := stdout.NewExporter(stdout.WithPrettyPrint())
-//if err != nil {
-//log.Fatal(err)
-//}
-
-//tp := tracesdk.NewTracerProvider(
-//tracesdk.WithSampler(tracesdk.AlwaysSample()),
-//tracesdk.WithSyncer(exporter),
-//tracesdk.WithResource(resource.NewWithAttributes(
-//semconv.ServiceNameKey.String(service),
-//attribute.String("environment", environment),
-//)),
-//)
-//if err != nil {
-//log.Fatal(err)
-//}
-//otel.SetTracerProvider(tp)
-//otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
-//}
+func newSampler(cfg Config) tracesdk.Sampler {
+	var root tracesdk.Sampler
+	switch cfg.Sampler {
+	case SamplerAlwaysOff, SamplerParentBasedAlwaysOff:
+		root = tracesdk.NeverSample()
+	case SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+		root = tracesdk.TraceIDRatioBased(cfg.SamplerArg)
+	default:
+		root = tracesdk.AlwaysSample()
+	}
+
+	// The rate limit only applies to root spans: children follow their
+	// parent, so a limited trace is never cut short.
+	var limited tracesdk.Sampler = root
+	if cfg.RateLimit > 0 {
+		limited = RateLimiting(cfg.RateLimit, root)
+	}
+
+	var sampler tracesdk.Sampler
+	switch cfg.Sampler {
+	case SamplerAlwaysOn, SamplerAlwaysOff, SamplerTraceIDRatio:
+		sampler = root
+		if cfg.RateLimit > 0 {
+			// Spans with a remote parent ignore its decision, as
+			// without a limit.
+			sampler = tracesdk.ParentBased(limited,
+				tracesdk.WithRemoteParentSampled(root),
+				tracesdk.WithRemoteParentNotSampled(root),
+			)
+		}
+	default:
+		sampler = tracesdk.ParentBased(limited)
+	}
+
+	rules := cfg.SamplingRules
+	if !cfg.SampleHealthChecks {
+		rules = append(append([]SamplingRule(nil), HealthCheckRules...), rules...)
+	}
+	if len(rules) == 0 {
+		return sampler
+	}
+	return RuleBased(rules, sampler)
+}